@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"log"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2"
 	"google.golang.org/grpc"
@@ -15,18 +18,20 @@ func main() {
 	grpcInitRoute := func(server *grpc.Server) {
 		// Example: Add gRPC routes here.
 	}
-	serverManager.AddServer(gossiper.NewGRPCServ("50051", grpc.NewServer(), grpcInitRoute))
+	// NewGRPCServer wires recovery and request-id interceptors; add your own
+	// at a named stage, e.g. WithGRPCUnaryInterceptor(GRPCStageAuth, ...).
+	serverManager.AddServer(gossiper.NewGRPCServ("50051", gossiper.NewGRPCServer(), grpcInitRoute))
 
-	// Initialize the REST server.
+	// Initialize the REST server. /healthz and /readyz are mounted
+	// automatically when the server is added to the manager.
 	restInitRoute := func(app *fiber.App) {
-		// Define a health check endpoint.
-		app.Get("/health", func(c *fiber.Ctx) error {
-			return c.JSON(fiber.Map{"status": "ok"})
-		})
+		// Example: Add REST routes here.
 	}
 	serverManager.AddServer(gossiper.NewRESTServ("8080", fiber.New(), restInitRoute))
 
-	// Start all servers.
-	serverManager.StartAll()
-	defer serverManager.StopAll()
+	// Start all servers and block until SIGINT/SIGTERM, then drain them
+	// in reverse order.
+	if err := serverManager.Run(context.Background()); err != nil {
+		log.Printf("shutdown finished with errors: %v", err)
+	}
 }
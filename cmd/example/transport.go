@@ -16,9 +16,9 @@ func NewSomeService() *SomeService {
 		"localhost:50051",
 	)
 
-	// Create the client only once and store it as a property
-	//clientConstructor := pb.NewSomeServiceClient
-	//client, err := grpcTransport.CreateClient(clientConstructor)
+	// Create the client only once and store it as a property.
+	// NewClient is typed, so no assertion on the result is needed.
+	//client, err := gossiper.NewClient(grpcTransport, pb.NewSomeServiceClient)
 	//if err != nil {
 	//	log.Fatalf("Error creating client: %v", err)
 	//}
@@ -29,26 +29,13 @@ func NewSomeService() *SomeService {
 	}
 }
 
-//func (s *SomeService) Items() ([]any, error) {
-//	ctx := context.Background()
-//
-//	// Send the request using the client stored in the SomeService instance
-//	response, err := s.transport.Send(
-//		ctx,
-//		s.client,
-//		"GetItems",
-//		&pb.GetItemsRequest{}, // Dynamic request for GetItems
-//	)
+//func (s *SomeService) Items(ctx context.Context) (*pb.GetItemsResponse, error) {
+//	// Call checks the method, request and response types at compile time
+//	// and applies the transport's middleware, default deadline and logging.
+//	res, err := gossiper.Call(ctx, s.transport, s.client.GetItems, &pb.GetItemsRequest{})
 //	if err != nil {
 //		log.Printf("Error sending request: %v", err)
 //		return nil, err
 //	}
-//
-//	// Assert the response to the correct type
-//	res, ok := response.(*pb.GetItemsResponse)
-//	if !ok {
-//		return nil, errors.New("invalid response type from gRPC transport")
-//	}
-//
 //	return res, nil
 //}
@@ -2,18 +2,26 @@ package gossiper
 
 import (
 	"context"
+	"crypto/tls"
 	"log/slog"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/db"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/generic"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/servers"
 	grpcServ "github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/servers/grpc"
 	restServ "github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/servers/http/fiber"
+	metricsServ "github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/servers/http/metrics"
+	rmqServ "github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/servers/rmq"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/tenant"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/tlsconfig"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/transport"
+	amqp "github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"gorm.io/gorm"
@@ -30,16 +38,32 @@ type DatabaseType = db.DatabaseType
 
 // PostgresDB One of Supported database types.
 const (
-	PostgresDB   DatabaseType = db.PostgresDB
+	PostgresDB DatabaseType = db.PostgresDB
 )
 
 // NewDB initializes a new database connection.
 // - dbType: The type of database (e.g., PostgresDB).
 // - dsn: The data source name for connecting to the database.
-// - enableLogs: Whether to enable logging for the database.
+// - enableLogs: Whether to log queries through ObservabilityLoggerFromContext.
+// Every query gets a client span and RED metrics regardless.
 // Returns a `Database` interface or an error if initialization fails.
-func NewDB(dbType DatabaseType, dsn string, enableLogs bool, autoMigrateModels []any) (Database, error) {
-	return db.New(dsn, enableLogs, autoMigrateModels).Create(dbType)
+func NewDB(dbType DatabaseType, dsn string, enableLogs bool, autoMigrateModels []any, opts ...DBOption) (Database, error) {
+	return db.New(dsn, enableLogs, autoMigrateModels, opts...).Create(dbType)
+}
+
+// DBOption configures a database created by NewDB.
+type DBOption = db.Option
+
+// WithDBLogger sets the base logger for query logs. Defaults to
+// slog.Default().
+func WithDBLogger(l *slog.Logger) DBOption {
+	return db.WithLogger(l)
+}
+
+// WithDBSlowQueryThreshold sets the duration above which a query is logged
+// as a slow-query warning. Defaults to 200ms; zero disables it.
+func WithDBSlowQueryThreshold(d time.Duration) DBOption {
+	return db.WithSlowThreshold(d)
 }
 
 // ServerManager aliases the server manager for managing multiple servers.
@@ -51,15 +75,140 @@ type GRPCServer = grpcServ.Server
 // RESTServer represents a REST server instance.
 type RESTServer = restServ.Server
 
+// RMQServer represents a RabbitMQ consumer server instance.
+type RMQServer = rmqServ.Server
+
+// MetricsServer serves the Prometheus /metrics endpoint on its own port.
+type MetricsServer = metricsServ.Server
+
+// ServerManagerOption configures a ServerManager created by NewServerManager.
+type ServerManagerOption = servers.Option
+
+// DefaultShutdownTimeout is the drain deadline ServerManager.Run uses unless
+// overridden with WithShutdownTimeout.
+const DefaultShutdownTimeout = servers.DefaultShutdownTimeout
+
+// StartPolicy decides how ServerManager.Run reacts to a server failing to
+// start or exiting unexpectedly.
+type StartPolicy = servers.StartPolicy
+
+const (
+	// AllOrNothing stops every other server and returns the failure from Run.
+	AllOrNothing StartPolicy = servers.AllOrNothing
+	// BestEffort logs the failure and keeps the remaining servers running.
+	BestEffort StartPolicy = servers.BestEffort
+)
+
 // NewServerManager creates a new instance of the server manager.
 // The server manager is responsible for starting and stopping multiple server instances.
-func NewServerManager() *ServerManager {
-	return servers.NewServerManager()
+func NewServerManager(opts ...ServerManagerOption) *ServerManager {
+	return servers.NewServerManager(opts...)
+}
+
+// WithShutdownTimeout sets how long ServerManager.Run waits for all servers
+// to drain after SIGINT/SIGTERM or context cancellation.
+func WithShutdownTimeout(d time.Duration) ServerManagerOption {
+	return servers.WithShutdownTimeout(d)
+}
+
+// WithDrainDelay sets how long ServerManager.Run reports NOT_SERVING
+// before it starts stopping servers, so health probes notice the shutdown
+// while the listeners are still open.
+func WithDrainDelay(d time.Duration) ServerManagerOption {
+	return servers.WithDrainDelay(d)
+}
+
+// WithStartPolicy selects how ServerManager.Run handles start failures.
+// Defaults to AllOrNothing.
+func WithStartPolicy(p StartPolicy) ServerManagerOption {
+	return servers.WithStartPolicy(p)
+}
+
+// Component is a non-server dependency managed by ServerManager.Run: it is
+// started before any server and stopped after every server has drained.
+// Register one with ServerManager.AddComponent(name, component, dependsOn...).
+type Component = servers.Component
+
+// ComponentHooks adapts a pair of OnStart/OnStop functions to Component.
+type ComponentHooks = servers.Hooks
+
+// DatabaseComponent wraps a Database so ServerManager closes its connection
+// pool only after all servers have drained. The database's ping is added to
+// the readiness checks under the component's name.
+func DatabaseComponent(database Database) Component {
+	return databaseComponent{Database: database}
+}
+
+type databaseComponent struct{ Database }
+
+func (databaseComponent) OnStart(context.Context) error { return nil }
+
+func (d databaseComponent) OnStop(context.Context) error {
+	sqlDB, err := d.GetDB().DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// ShutdownComponent wraps a shutdown function, such as the one returned by
+// InitObservability, so it runs after all servers have drained.
+func ShutdownComponent(shutdown func(context.Context) error) Component {
+	return ComponentHooks{Stop: shutdown}
+}
+
+// TenantSyncComponent syncs the given tenants when ServerManager.Run starts,
+// before any server accepts traffic. The tenant manager's health is added
+// to the readiness checks under the component's name.
+func TenantSyncComponent(manager *TenantManager, tenants []EncryptedTenant) Component {
+	return tenantSyncComponent{manager: manager, tenants: tenants}
+}
+
+type tenantSyncComponent struct {
+	manager *TenantManager
+	tenants []EncryptedTenant
+}
+
+func (t tenantSyncComponent) OnStart(context.Context) error {
+	return t.manager.SyncTenants(&t.tenants)
+}
+
+func (tenantSyncComponent) OnStop(context.Context) error { return nil }
+
+func (t tenantSyncComponent) HealthCheck(ctx context.Context) error {
+	return t.manager.HealthCheck(ctx)
+}
+
+// HealthRegistry holds the readiness checks behind the gRPC health service
+// and the /healthz and /readyz routes. Get the manager's registry with
+// ServerManager.Health().
+type HealthRegistry = health.Registry
+
+// HealthCheck reports whether a dependency is usable; nil means healthy.
+type HealthCheck = health.Check
+
+// HealthChecker is implemented by dependencies that can report their own
+// health, such as Database and the gRPC transport.
+type HealthChecker = health.Checker
+
+// HealthReport is the readiness result served on /readyz.
+type HealthReport = health.Report
+
+// TransportHealthCheck returns a readiness check for t. Transports that do
+// not track connection health always report healthy.
+func TransportHealthCheck(t Transport) HealthCheck {
+	return func(ctx context.Context) error {
+		if hc, ok := t.(HealthChecker); ok {
+			return hc.HealthCheck(ctx)
+		}
+		return nil
+	}
 }
 
 // NewGRPCServ creates a new gRPC server.
 // - port: The port number for the server.
-// - server: The gRPC server instance.
+// - server: The gRPC server instance. For TLS/mTLS, build it with
+// NewDefaultGRPCServer(GRPCServerTLS(tlsCfg)).
 // - initRoute: A function to initialize the server's routes.
 // Returns a `GRPCServer` instance.
 func NewGRPCServ(port string, server *grpc.Server, initRoute func(server *grpc.Server)) *GRPCServer {
@@ -68,11 +217,95 @@ func NewGRPCServ(port string, server *grpc.Server, initRoute func(server *grpc.S
 
 // NewDefaultGRPCServer creates a grpc.Server with OTel trace propagation pre-wired.
 // Use this instead of grpc.NewServer() so incoming trace context is automatically
-// received and propagated through the server's handler chain.
+// received and propagated through the server's handler chain. NewGRPCServer
+// additionally wires the recovery, request-id and other interceptors.
 func NewDefaultGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
 	return grpcServ.NewDefaultServer(opts...)
 }
 
+// GRPCStage is a named position in the interceptor chain of NewGRPCServer.
+type GRPCStage = grpcServ.Stage
+
+const (
+	GRPCStageRecovery      GRPCStage = grpcServ.StageRecovery
+	GRPCStageRequestID     GRPCStage = grpcServ.StageRequestID
+	GRPCStageObservability GRPCStage = grpcServ.StageObservability
+	GRPCStageAuth          GRPCStage = grpcServ.StageAuth
+	GRPCStageTenant        GRPCStage = grpcServ.StageTenant
+	GRPCStageValidation    GRPCStage = grpcServ.StageValidation
+	GRPCStageRateLimit     GRPCStage = grpcServ.StageRateLimit
+)
+
+// GRPCServerOption configures a server built by NewGRPCServer.
+type GRPCServerOption = grpcServ.Option
+
+// NewGRPCServer creates a grpc.Server with the OTel stats handler and an
+// ordered interceptor chain for unary and stream calls: recovery, request
+// ID, tracing/logging, auth, tenant, validation, rate limiting.
+//
+//	server := gossiper.NewGRPCServer(
+//		gossiper.WithGRPCObservability(logger, tracer),
+//		gossiper.WithGRPCUnaryInterceptor(gossiper.GRPCStageAuth, authInterceptor),
+//		gossiper.WithGRPCServerOptions(gossiper.GRPCServerTLS(tlsCfg)),
+//	)
+func NewGRPCServer(opts ...GRPCServerOption) *grpc.Server {
+	return grpcServ.NewServer(opts...)
+}
+
+// WithGRPCServerOptions passes opts to grpc.NewServer.
+func WithGRPCServerOptions(opts ...grpc.ServerOption) GRPCServerOption {
+	return grpcServ.WithServerOptions(opts...)
+}
+
+// WithGRPCUnaryInterceptor inserts a unary interceptor at stage.
+func WithGRPCUnaryInterceptor(stage GRPCStage, i grpc.UnaryServerInterceptor) GRPCServerOption {
+	return grpcServ.WithUnaryInterceptor(stage, i)
+}
+
+// WithGRPCStreamInterceptor inserts a stream interceptor at stage.
+func WithGRPCStreamInterceptor(stage GRPCStage, i grpc.StreamServerInterceptor) GRPCServerOption {
+	return grpcServ.WithStreamInterceptor(stage, i)
+}
+
+// WithGRPCObservability adds the tracing and logging interceptors, with the
+// tenant and user metadata keys and identity extractor set by opts.
+func WithGRPCObservability(logger *slog.Logger, tracer trace.Tracer, opts ...ObservabilityMiddlewareOption) GRPCServerOption {
+	return grpcServ.WithObservability(logger, tracer, opts...)
+}
+
+// WithGRPCTenant adds the tenant resolution interceptors at
+// GRPCStageTenant.
+func WithGRPCTenant(registry TenantRegistry, database Database, opts ...TenantMiddlewareOption) GRPCServerOption {
+	return grpcServ.WithTenant(registry, database, opts...)
+}
+
+// WithGRPCValidation rejects requests whose Validate() method fails with
+// codes.InvalidArgument.
+func WithGRPCValidation() GRPCServerOption {
+	return grpcServ.WithValidation()
+}
+
+// TLSConfig describes certificate files for TLS/mTLS on gRPC servers and
+// transports, with hot reload of rotated files and optional SPIFFE ID
+// verification.
+type TLSConfig = tlsconfig.Config
+
+// NewServerTLSConfig builds a server-side *tls.Config from cfg.
+func NewServerTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	return tlsconfig.ServerConfig(cfg)
+}
+
+// NewClientTLSConfig builds a client-side *tls.Config from cfg.
+func NewClientTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	return tlsconfig.ClientConfig(cfg)
+}
+
+// GRPCServerTLS returns a server option that terminates TLS with cfg.
+// Pass it to NewDefaultGRPCServer.
+func GRPCServerTLS(cfg *tls.Config) grpc.ServerOption {
+	return grpcServ.TLSServerOption(cfg)
+}
+
 // RecoveryUnaryServerInterceptor recovers from panics in a gRPC handler and
 // converts them into a codes.Internal error instead of letting them crash
 // the whole process for every tenant sharing it. Put it first in your
@@ -82,13 +315,21 @@ func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	return grpcServ.RecoveryUnaryServerInterceptor()
 }
 
-// InitLogger sets the global slog logger to JSON output on stderr.
-// Call once at the top of main() so all log output is structured and
-// compatible with log aggregators (CloudWatch, Loki, etc.).
+// RecoveryStreamServerInterceptor is the streaming counterpart of
+// RecoveryUnaryServerInterceptor. Put it first in your service's
+// grpc.ChainStreamInterceptor(...) call.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return grpcServ.RecoveryStreamServerInterceptor()
+}
+
+// InitLogger sets the global slog logger to JSON output on stderr, with
+// DefaultRedactedKeys masked. Call once at the top of main() so all log
+// output is structured and compatible with log aggregators (CloudWatch,
+// Loki, etc.).
 func InitLogger() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+	slog.SetDefault(slog.New(observability.NewRedactHandler(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
-	})))
+	}), observability.DefaultRedactedKeys...)))
 }
 
 // NewRESTServ creates a new REST server.
@@ -100,19 +341,282 @@ func NewRESTServ(port string, router *fiber.App, initRoute func(router *fiber.Ap
 	return restServ.New(port, router, initRoute)
 }
 
+// RMQTopology describes the exchange, queue, routing keys, dead-letter
+// exchange and prefetch a RabbitMQ server declares before consuming.
+type RMQTopology = rmqServ.Topology
+
+// RMQHandler processes one RabbitMQ delivery. Returning nil acks it, an
+// error dead-letters it unless wrapped with RMQRequeue.
+type RMQHandler = rmqServ.Handler
+
+// NewRMQServ creates a new RabbitMQ consumer server.
+// - url: The AMQP URL of the broker.
+// - topology: The exchange, queue and dead-letter routing to declare.
+// - handler: Called for every delivery with the trace context and
+// request ID restored from the message headers.
+// Returns a `RMQServer` instance.
+func NewRMQServ(url string, topology RMQTopology, handler RMQHandler) *RMQServer {
+	return rmqServ.New(url, topology, handler)
+}
+
+// NewMetricsServ creates a server exposing GET /metrics on port, for
+// services that do not mount MetricsFiberHandler on a REST server.
+func NewMetricsServ(port string) *MetricsServer {
+	return metricsServ.New(port)
+}
+
+// RMQRequeue wraps a handler error so the delivery is returned to the queue
+// instead of being dead-lettered.
+func RMQRequeue(err error) error {
+	return rmqServ.Requeue(err)
+}
+
+// RMQDelivery is a message received by an RMQHandler.
+type RMQDelivery = amqp.Delivery
+
 type Transport = transport.Transport
 type TransportType = transport.Type
 
 const (
 	GRPC TransportType = transport.GRPC
+	RMQ  TransportType = transport.RMQ
+	// HTTP calls JSON endpoints such as those served by NewRESTServ; the
+	// address is a base URL like "http://billing:8080".
+	HTTP TransportType = transport.HTTP
+	// InProcess routes calls to a gRPC server in the same process (see
+	// ServeInProcess) without a network listener.
+	InProcess TransportType = transport.InProcess
 )
 
+// ServeInProcess makes server reachable by InProcess transports under
+// name, without opening a port. Useful in tests; servers started by a
+// ServerManager are registered under their port automatically. stop
+// unregisters it without stopping server.
+func ServeInProcess(name string, server *grpc.Server) (stop func(), err error) {
+	return transport.ServeInProcess(name, server)
+}
+
 type TransportFactory = transport.Factory
 
+// TransportOption configures a transport created by TransportFactory.CreateTransport.
+type TransportOption = transport.Option
+
+// WithTransportTLS secures the transport's connections with cfg, typically
+// built by NewClientTLSConfig.
+func WithTransportTLS(cfg *tls.Config) TransportOption {
+	return transport.WithTLS(cfg)
+}
+
 func NewTransportFactory() *TransportFactory {
 	return transport.NewFactory()
 }
 
+// WithRMQExchange sets the exchange an RMQ transport publishes to.
+func WithRMQExchange(name string) TransportOption {
+	return transport.WithExchange(name)
+}
+
+// WithTransportPoolSize keeps n connections to the transport's target and
+// spreads calls across them. The default is one shared connection.
+func WithTransportPoolSize(n int) TransportOption {
+	return transport.WithPoolSize(n)
+}
+
+// TransportPolicy sets the deadline, retry and hedging behaviour of a
+// transport, with per-method overrides keyed by "package.Service/Method"
+// or "package.Service".
+type TransportPolicy = transport.Policy
+
+// CallPolicy is one level of a TransportPolicy; zero fields inherit.
+type CallPolicy = transport.CallPolicy
+
+// RetryPolicy retries failed calls with exponential backoff.
+type RetryPolicy = transport.RetryPolicy
+
+// HedgingPolicy sends parallel copies of idempotent calls.
+type HedgingPolicy = transport.HedgingPolicy
+
+// DefaultTransportPolicy returns the policy transports use unless
+// configured otherwise: a 10s deadline and up to 3 attempts on
+// UNAVAILABLE and RESOURCE_EXHAUSTED.
+func DefaultTransportPolicy() TransportPolicy {
+	return transport.DefaultPolicy()
+}
+
+// WithTransportPolicy replaces the transport's default policy.
+func WithTransportPolicy(p TransportPolicy) TransportOption {
+	return transport.WithPolicy(p)
+}
+
+// WithCallPolicy overrides the transport policy for calls made with the
+// returned context.
+func WithCallPolicy(ctx context.Context, p CallPolicy) context.Context {
+	return transport.WithCallPolicy(ctx, p)
+}
+
+// WithCallTimeout overrides the transport's timeout for calls made with
+// the returned context when it has no deadline of its own.
+func WithCallTimeout(ctx context.Context, d time.Duration) context.Context {
+	return transport.WithCallTimeout(ctx, d)
+}
+
+// BreakerConfig configures the opt-in circuit breaker on a transport.
+type BreakerConfig = transport.BreakerConfig
+
+// BreakerState is the state of a circuit breaker (closed, open, half-open).
+type BreakerState = transport.BreakerState
+
+// CircuitOpenError is returned without contacting the target while its
+// circuit breaker is open. It reports codes.Unavailable to status.Code.
+type CircuitOpenError = transport.CircuitOpenError
+
+// WithCircuitBreaker enables a circuit breaker on a gRPC, HTTP or RMQ
+// transport, per target or per method (BreakerConfig.PerMethod). State
+// changes are logged and recorded as span events.
+func WithCircuitBreaker(cfg BreakerConfig) TransportOption {
+	return transport.WithCircuitBreaker(cfg)
+}
+
+// Resolver discovers the endpoint addresses of a transport's target.
+type Resolver = transport.Resolver
+
+// ResolverFunc adapts a function to Resolver.
+type ResolverFunc = transport.ResolverFunc
+
+// StaticResolver always resolves to addrs.
+func StaticResolver(addrs ...string) Resolver {
+	return transport.StaticResolver(addrs...)
+}
+
+// DNSSRVResolver resolves the SRV records _service._proto.name.
+func DNSSRVResolver(service, proto, name string) Resolver {
+	return transport.DNSSRVResolver(service, proto, name)
+}
+
+// FileResolver reads one address per line from path and picks up changes
+// to the file.
+func FileResolver(path string) Resolver {
+	return transport.FileResolver(path)
+}
+
+// WithResolver balances the transport's calls across the endpoints found by
+// r, re-resolving every DefaultResolveInterval.
+func WithResolver(r Resolver) TransportOption {
+	return transport.WithResolver(r)
+}
+
+// DefaultResolveInterval is how often resolvers are polled by default.
+const DefaultResolveInterval = transport.DefaultResolveInterval
+
+// WithResolveInterval sets how often the transport's resolver is polled.
+func WithResolveInterval(d time.Duration) TransportOption {
+	return transport.WithResolveInterval(d)
+}
+
+// Balancer chooses the endpoint for each call of a transport with a
+// resolver.
+type Balancer = transport.Balancer
+
+const (
+	RoundRobin   Balancer = transport.RoundRobin
+	LeastRequest Balancer = transport.LeastRequest
+	TenantHash   Balancer = transport.TenantHash
+)
+
+// WithBalancer selects the balancing policy; the default is RoundRobin.
+func WithBalancer(b Balancer) TransportOption {
+	return transport.WithBalancer(b)
+}
+
+// EndpointHealthConfig controls when endpoints are ejected after failed
+// calls.
+type EndpointHealthConfig = transport.EndpointHealthConfig
+
+// WithEndpointHealth tunes passive health tracking of resolved endpoints.
+func WithEndpointHealth(cfg EndpointHealthConfig) TransportOption {
+	return transport.WithEndpointHealth(cfg)
+}
+
+// ErrTransportClosed is returned when a transport is used after Close.
+var ErrTransportClosed = transport.ErrTransportClosed
+
+// TransportComponent registers t with a ServerManager so its connections
+// are closed after all servers have drained, and its connection health
+// takes part in readiness.
+func TransportComponent(t Transport) Component {
+	return transportComponent{t: t}
+}
+
+type transportComponent struct{ t Transport }
+
+func (transportComponent) OnStart(context.Context) error { return nil }
+
+func (c transportComponent) OnStop(context.Context) error { return c.t.Close() }
+
+func (c transportComponent) HealthCheck(ctx context.Context) error {
+	return TransportHealthCheck(c.t)(ctx)
+}
+
+// UnaryMethod is the shape of a unary method on a protoc-generated gRPC
+// client, e.g. pb.SomeServiceClient.GetItems.
+type UnaryMethod[Req, Resp any] = transport.UnaryMethod[Req, Resp]
+
+// Call invokes a generated client method through t with the transport's
+// context middleware, default deadline and logging. Unlike Transport.Send,
+// the method, request and response types are checked at compile time:
+//
+//	resp, err := gossiper.Call(ctx, t, client.GetItems, &pb.GetItemsRequest{})
+func Call[Req, Resp any](ctx context.Context, t Transport, method UnaryMethod[Req, Resp], req Req, opts ...grpc.CallOption) (Resp, error) {
+	return transport.Call(ctx, t, method, req, opts...)
+}
+
+// NewClient creates a typed client on t from a protoc-generated constructor:
+//
+//	client, err := gossiper.NewClient(t, pb.NewSomeServiceClient)
+func NewClient[T any](t Transport, constructor func(cc grpc.ClientConnInterface) T) (T, error) {
+	return transport.NewClient(t, constructor)
+}
+
+// ServerStreamMethod is the shape of a server-streaming method on a
+// protoc-generated gRPC client.
+type ServerStreamMethod[Req, Resp any] = transport.ServerStreamMethod[Req, Resp]
+
+// ClientStreamMethod is the shape of a client-streaming method.
+type ClientStreamMethod[Req, Resp any] = transport.ClientStreamMethod[Req, Resp]
+
+// BidiStreamMethod is the shape of a bidirectional streaming method.
+type BidiStreamMethod[Req, Resp any] = transport.BidiStreamMethod[Req, Resp]
+
+// ServerStream opens a server stream through t with the transport's context
+// middleware (request ID and trace metadata). Read until Recv returns an
+// error or cancel ctx:
+//
+//	stream, err := gossiper.ServerStream(ctx, t, client.ExportItems, &pb.ExportRequest{})
+func ServerStream[Req, Resp any](ctx context.Context, t Transport, method ServerStreamMethod[Req, Resp], req Req, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Resp], error) {
+	return transport.ServerStream(ctx, t, method, req, opts...)
+}
+
+// ClientStream opens a client stream through t; finish it with CloseAndRecv.
+func ClientStream[Req, Resp any](ctx context.Context, t Transport, method ClientStreamMethod[Req, Resp], opts ...grpc.CallOption) (grpc.ClientStreamingClient[Req, Resp], error) {
+	return transport.ClientStream(ctx, t, method, opts...)
+}
+
+// BidiStream opens a bidirectional stream through t.
+func BidiStream[Req, Resp any](ctx context.Context, t Transport, method BidiStreamMethod[Req, Resp], opts ...grpc.CallOption) (grpc.BidiStreamingClient[Req, Resp], error) {
+	return transport.BidiStream(ctx, t, method, opts...)
+}
+
+// HTTPError is returned by HTTP transports for 4xx and 5xx responses. It
+// maps onto a gRPC status, so status.Code works on it.
+type HTTPError = transport.HTTPError
+
+// SendJSON calls an HTTP transport and decodes the JSON response:
+//
+//	order, err := gossiper.SendJSON[Order](ctx, t, "GET /orders/42", nil)
+func SendJSON[Resp any](ctx context.Context, t Transport, method string, request any) (Resp, error) {
+	return transport.SendJSON[Resp](ctx, t, method, request)
+}
+
 // Filter [T] is an alias for generic.Filter[T], providing a filter structure.
 type Filter[T any] struct {
 	generic.Filter[T]
@@ -178,16 +682,110 @@ type ITenantManager = tenant.ITenantManager
 type TenantManager = tenant.Manager
 type EncryptedTenant = tenant.EncryptedTenant
 
-//type RawTenant struct {
-//	database string // Schema name / namespace name
-//	username string
-//	password string
-//}
+// TenantInfo describes a tenant of the registry persisted by TenantManager.
+// Pass one with Username and Password to TenantManager.EncryptTenant.
+type TenantInfo = tenant.TenantInfo
+
+// TenantStatus is the provisioning state of a registered tenant.
+type TenantStatus = tenant.Status
+
+const (
+	TenantStatusPending = tenant.StatusPending
+	TenantStatusActive  = tenant.StatusActive
+	TenantStatusFailed  = tenant.StatusFailed
+)
+
+// TenantRegistryTable is the table TenantManager persists tenants in.
+const TenantRegistryTable = tenant.RegistryTable
+
+// ErrTenantNotFound is returned by TenantManager.Get and SetSchemaVersion
+// for unregistered namespaces.
+var ErrTenantNotFound = tenant.ErrTenantNotFound
 
 func NewTenantManager(db *gorm.DB, secret string) (*TenantManager, error) {
 	return tenant.NewTenantManager(db, secret)
 }
 
+// TenantRegistry tells the tenant middleware which tenants exist;
+// TenantManager implements it.
+type TenantRegistry = tenant.Registry
+
+// TenantSource is what a TenantResolver can inspect: request headers or
+// gRPC metadata, and the host.
+type TenantSource = tenant.Source
+
+// TenantResolver returns the tenant named by a request, or "".
+type TenantResolver = tenant.Resolver
+
+// TenantMiddlewareOption configures TenantFiberMiddleware and the tenant
+// gRPC interceptors.
+type TenantMiddlewareOption = tenant.MiddlewareOption
+
+// ErrNoTenant is returned by TenantTransaction outside the tenant
+// middleware.
+var ErrNoTenant = tenant.ErrNoTenant
+
+// TenantFromHeader reads the tenant from a header or metadata key.
+func TenantFromHeader(key string) TenantResolver {
+	return tenant.FromHeader(key)
+}
+
+// TenantFromSubdomain reads the tenant from the subdomain of baseDomain.
+func TenantFromSubdomain(baseDomain string) TenantResolver {
+	return tenant.FromSubdomain(baseDomain)
+}
+
+// TenantFromJWTClaim reads the tenant from a claim of the bearer token,
+// without verifying it.
+func TenantFromJWTClaim(claim string) TenantResolver {
+	return tenant.FromJWTClaim(claim)
+}
+
+// FirstTenantResolver returns the tenant of the first resolver that finds
+// one.
+func FirstTenantResolver(resolvers ...TenantResolver) TenantResolver {
+	return tenant.FirstOf(resolvers...)
+}
+
+// WithTenantResolver sets how the tenant middleware reads the tenant. The
+// default reads the DefaultTenantKey header or metadata key.
+func WithTenantResolver(r TenantResolver) TenantMiddlewareOption {
+	return tenant.WithResolver(r)
+}
+
+// WithTenantExempt lets paths or gRPC methods equal to or below one of
+// paths through without a tenant. The gRPC health service, /healthz and
+// /readyz always are.
+func WithTenantExempt(paths ...string) TenantMiddlewareOption {
+	return tenant.WithExempt(paths...)
+}
+
+// TenantFiberMiddleware validates the tenant of each request against
+// registry. Handlers reach the tenant's schema of database through
+// TenantTransaction.
+func TenantFiberMiddleware(registry TenantRegistry, database Database, opts ...TenantMiddlewareOption) func(*fiber.Ctx) error {
+	return tenant.FiberMiddleware(registry, database, opts...)
+}
+
+// TenantGRPCServerInterceptor is the gRPC counterpart of
+// TenantFiberMiddleware. WithGRPCTenant registers it at GRPCStageTenant.
+func TenantGRPCServerInterceptor(registry TenantRegistry, database Database, opts ...TenantMiddlewareOption) grpc.UnaryServerInterceptor {
+	return tenant.GRPCServerInterceptor(registry, database, opts...)
+}
+
+// TenantGRPCStreamServerInterceptor is the streaming counterpart of
+// TenantGRPCServerInterceptor.
+func TenantGRPCStreamServerInterceptor(registry TenantRegistry, database Database, opts ...TenantMiddlewareOption) grpc.StreamServerInterceptor {
+	return tenant.GRPCStreamServerInterceptor(registry, database, opts...)
+}
+
+// TenantTransaction runs fn in a transaction on the request's tenant
+// schema, committed when fn returns nil. It fails with ErrNoTenant outside
+// the tenant middleware, so handlers never fall back to the public schema.
+func TenantTransaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return tenant.Transaction(ctx, fn)
+}
+
 // GenerateRandomString creates a random alphanumeric string of the given length.
 func GenerateRandomString(length int) string {
 	return generic.GenerateRandomString(length)
@@ -206,7 +804,46 @@ func DecryptAES256(key, encrypted string) (string, error) {
 // ObservabilityConfig holds settings for initialising the observability stack.
 type ObservabilityConfig = observability.Config
 
-// InitObservability sets up the OTLP tracer provider and a structured JSON logger.
+// ObservabilitySamplingConfig sets per-route and per-method sampling ratios,
+// routes that are never traced, and whether failed or slow traces are kept
+// regardless of the ratio. Health probes (/healthz, /readyz and the gRPC
+// health service) are never traced.
+type ObservabilitySamplingConfig = observability.SamplingConfig
+
+// ObservabilityExporter selects where InitObservability sends spans.
+type ObservabilityExporter = observability.Exporter
+
+const (
+	// ObservabilityExporterOTLP sends spans to the OTLP collector (default).
+	ObservabilityExporterOTLP = observability.ExporterOTLP
+	// ObservabilityExporterStdout writes spans as JSON to stdout.
+	ObservabilityExporterStdout = observability.ExporterStdout
+	// ObservabilityExporterFile writes spans as JSON to ExportFile.
+	ObservabilityExporterFile = observability.ExporterFile
+	// ObservabilityExporterNone exports nothing; logging and trace IDs still
+	// work.
+	ObservabilityExporterNone = observability.ExporterNone
+)
+
+// OTLPProtocol is the OTLP transport protocol.
+type OTLPProtocol = observability.Protocol
+
+const (
+	OTLPProtocolHTTP = observability.ProtocolHTTP
+	OTLPProtocolGRPC = observability.ProtocolGRPC
+)
+
+// OTLPCompression is the OTLP payload compression.
+type OTLPCompression = observability.Compression
+
+const (
+	OTLPCompressionNone = observability.CompressionNone
+	OTLPCompressionGzip = observability.CompressionGzip
+)
+
+// InitObservability sets up the tracer provider with the exporter selected in
+// cfg, a MeterProvider exposed by MetricsHandler, and a structured JSON logger
+// that with ExportLogs also sends its records over OTLP.
 // Returns logger, tracer, shutdown func, and any init error.
 // On error fall back to slog.Default() and trace.NewNoopTracerProvider().Tracer("noop").
 func InitObservability(ctx context.Context, cfg ObservabilityConfig) (*slog.Logger, trace.Tracer, func(context.Context) error, error) {
@@ -216,15 +853,101 @@ func InitObservability(ctx context.Context, cfg ObservabilityConfig) (*slog.Logg
 // ObservabilityFiberMiddleware instruments all incoming HTTP requests with trace context
 // propagation, request_id generation, span creation, and structured logging.
 // Must be registered before route handlers so every request gets a trace.
-func ObservabilityFiberMiddleware(logger *slog.Logger, tracer trace.Tracer) func(*fiber.Ctx) error {
-	return observability.FiberMiddleware(logger, tracer)
+func ObservabilityFiberMiddleware(logger *slog.Logger, tracer trace.Tracer, opts ...ObservabilityMiddlewareOption) func(*fiber.Ctx) error {
+	return observability.FiberMiddleware(logger, tracer, opts...)
+}
+
+// ObservabilityMiddlewareOption configures ObservabilityFiberMiddleware and
+// the gRPC server interceptors.
+type ObservabilityMiddlewareOption = observability.MiddlewareOption
+
+const (
+	// DefaultTenantKey is the header and metadata key the tenant is read
+	// from and propagated under.
+	DefaultTenantKey = observability.DefaultTenantKey
+	// DefaultUserKey is the metadata key the user is propagated under to
+	// downstream gRPC services.
+	DefaultUserKey = observability.DefaultUserKey
+)
+
+// WithTenantKey sets the header and metadata key carrying the tenant.
+func WithTenantKey(key string) ObservabilityMiddlewareOption {
+	return observability.WithTenantKey(key)
+}
+
+// WithUserKey sets the metadata key carrying the user between services.
+func WithUserKey(key string) ObservabilityMiddlewareOption {
+	return observability.WithUserKey(key)
+}
+
+// WithTrustedUserPropagation makes the gRPC server interceptors accept the
+// user sent by the caller under the user key. Any client can set it, so
+// only use this on servers reachable solely by trusted services.
+func WithTrustedUserPropagation() ObservabilityMiddlewareOption {
+	return observability.WithTrustedUserPropagation()
+}
+
+// WithIdentityExtractor sets how the user field logged with each request is
+// derived. The default logs the JWT subject of a bearer token or the mTLS
+// client certificate's common name, never the raw Authorization header.
+func WithIdentityExtractor(e IdentityExtractor) ObservabilityMiddlewareOption {
+	return observability.WithIdentityExtractor(e)
+}
+
+// IdentityCredentials is what an IdentityExtractor can inspect: request
+// headers and the TLS connection state.
+type IdentityCredentials = observability.Credentials
+
+// IdentityExtractor returns the caller identity logged as the user field,
+// or "" when it finds none. It must never return secrets.
+type IdentityExtractor = observability.IdentityExtractor
+
+// FirstIdentity returns the identity of the first extractor that finds one.
+func FirstIdentity(extractors ...IdentityExtractor) IdentityExtractor {
+	return observability.FirstIdentity(extractors...)
+}
+
+// JWTSubjectIdentity identifies callers by the unverified "sub" claim of
+// their bearer token.
+func JWTSubjectIdentity() IdentityExtractor {
+	return observability.JWTSubject()
+}
+
+// APIKeyIdentity identifies callers by the id part ("<id>.<secret>") or a
+// fingerprint of the API key sent in header.
+func APIKeyIdentity(header string) IdentityExtractor {
+	return observability.APIKeyID(header)
+}
+
+// MTLSCommonNameIdentity identifies callers by the common name of their
+// verified client certificate.
+func MTLSCommonNameIdentity() IdentityExtractor {
+	return observability.MTLSCommonName()
 }
 
-// ObservabilityGRPCServerInterceptor adds trace propagation, request_id extraction,
-// span creation, and structured logging to every incoming gRPC unary call.
+// NewRedactHandler wraps next so that attributes and header values under
+// keys (case-insensitive) are logged as "[REDACTED]", as are bearer and
+// basic credentials. InitObservability applies it with
+// DefaultRedactedKeys plus ObservabilityConfig.RedactKeys.
+func NewRedactHandler(next slog.Handler, keys ...string) slog.Handler {
+	return observability.NewRedactHandler(next, keys...)
+}
+
+// DefaultRedactedKeys are the attribute keys and header names masked by the
+// loggers of InitObservability and InitLogger.
+var DefaultRedactedKeys = observability.DefaultRedactedKeys
+
+// NewFanoutHandler returns a slog handler writing every record to all of
+// handlers, each filtered by its own level.
+func NewFanoutHandler(handlers ...slog.Handler) slog.Handler {
+	return observability.NewFanoutHandler(handlers...)
+}
+
+// ObservabilityGRPCServerInterceptor adds trace propagation, request_id, tenant and
+// user extraction, span creation, and structured logging to every incoming gRPC unary call.
 // Use as grpc.UnaryInterceptor on the gRPC server.
-func ObservabilityGRPCServerInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.UnaryServerInterceptor {
-	return observability.GRPCServerInterceptor(logger, tracer)
+func ObservabilityGRPCServerInterceptor(logger *slog.Logger, tracer trace.Tracer, opts ...ObservabilityMiddlewareOption) grpc.UnaryServerInterceptor {
+	return observability.GRPCServerInterceptor(logger, tracer, opts...)
 }
 
 // ObservabilityGRPCClientInterceptor propagates request_id and OTel trace headers on
@@ -234,14 +957,28 @@ func ObservabilityGRPCClientInterceptor(logger *slog.Logger, tracer trace.Tracer
 	return observability.GRPCClientInterceptor(logger, tracer)
 }
 
+// ObservabilityGRPCStreamServerInterceptor is the streaming counterpart of
+// ObservabilityGRPCServerInterceptor. Use as grpc.StreamInterceptor on the
+// gRPC server.
+func ObservabilityGRPCStreamServerInterceptor(logger *slog.Logger, tracer trace.Tracer, opts ...ObservabilityMiddlewareOption) grpc.StreamServerInterceptor {
+	return observability.GRPCStreamServerInterceptor(logger, tracer, opts...)
+}
+
+// ObservabilityGRPCStreamClientInterceptor is the streaming counterpart of
+// ObservabilityGRPCClientInterceptor. Use as grpc.WithStreamInterceptor on
+// gRPC client connections.
+func ObservabilityGRPCStreamClientInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.StreamClientInterceptor {
+	return observability.GRPCStreamClientInterceptor(logger, tracer)
+}
+
 // ObservabilityLoggerFromContext enriches logger with request_id, trace_id, span_id,
 // tenant, and user fields from the request context.
 func ObservabilityLoggerFromContext(ctx context.Context, base *slog.Logger) *slog.Logger {
 	return observability.LoggerFromContext(ctx, base)
 }
 
-// ObservabilityWithOutgoingMetadata injects request_id and OTel trace headers into the
-// outgoing gRPC metadata so downstream services can correlate their logs.
+// ObservabilityWithOutgoingMetadata injects request_id, tenant, user and OTel trace headers
+// into the outgoing gRPC metadata so downstream services can correlate their logs.
 func ObservabilityWithOutgoingMetadata(ctx context.Context) context.Context {
 	return observability.WithOutgoingMetadata(ctx)
 }
@@ -251,6 +988,50 @@ func ObservabilityRequestID(ctx context.Context) string {
 	return observability.RequestID(ctx)
 }
 
+// TenantFromContext returns the tenant of the request, or "" when it has
+// none.
+func TenantFromContext(ctx context.Context) string {
+	return observability.Tenant(ctx)
+}
+
+// UserFromContext returns the user identified for the request, or "" when
+// it has none.
+func UserFromContext(ctx context.Context) string {
+	return observability.User(ctx)
+}
+
+// AttributeTenant is the request attribute carrying the tenant; it is always
+// propagated.
+const AttributeTenant = observability.AttributeTenant
+
+// WithRequestAttribute sets a request attribute, such as a locale or a
+// feature-flag cohort, carried as W3C baggage to downstream HTTP and gRPC
+// services. Attributes declared in ObservabilityConfig.RequestAttributes
+// are logged by ObservabilityLoggerFromContext and set on spans.
+func WithRequestAttribute(ctx context.Context, key, value string) context.Context {
+	return observability.WithRequestAttribute(ctx, key, value)
+}
+
+// RequestAttribute returns a request attribute set by this service or an
+// upstream one, or "".
+func RequestAttribute(ctx context.Context, key string) string {
+	return observability.RequestAttribute(ctx, key)
+}
+
+// MetricsHandler serves the RED metrics (request count, error count and
+// latency histograms) recorded by the observability middleware, the
+// interceptors and the database layer, in the Prometheus text format.
+func MetricsHandler() http.Handler {
+	return observability.MetricsHandler()
+}
+
+// MetricsFiberHandler is MetricsHandler as a fiber handler:
+//
+//	app.Get("/metrics", gossiper.MetricsFiberHandler())
+func MetricsFiberHandler() fiber.Handler {
+	return observability.MetricsFiberHandler()
+}
+
 // RegisterTransportContextMiddleware sets a function that enriches the context
 // before every outgoing gRPC call made by the gossiper transport.
 // Call once at startup — typically to inject x-request-id and W3C traceparent
@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"time"
+
 	postgresql "github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/db/pg"
 	"gorm.io/gorm"
 )
@@ -24,20 +27,38 @@ type Database interface {
 	// work against a shared connection pool.
 	WithSchema(ctx context.Context, schema string, fn func(tx *gorm.DB) error) error
 	MigrateTenants(schemas []string, autoMigrateEntities []any) error
+	// HealthCheck pings the database; it satisfies health.Checker so the
+	// database can take part in readiness probes.
+	HealthCheck(ctx context.Context) error
 }
 
 // DatabaseType defines the type of databases supported
 type DatabaseType int
 
+// Option configures the databases created by a DatabaseFactory.
+type Option = postgresql.Option
+
+// WithLogger sets the base logger for query logs.
+func WithLogger(l *slog.Logger) Option {
+	return postgresql.WithLogger(l)
+}
+
+// WithSlowThreshold sets the duration above which a query is logged as
+// slow.
+func WithSlowThreshold(d time.Duration) Option {
+	return postgresql.WithSlowThreshold(d)
+}
+
 // DatabaseFactory is a factory for creating database instances
 type DatabaseFactory struct {
 	dsn               string
 	enableLogs        bool
 	autoMigrateModels []any
+	opts              []Option
 }
 
 // New initializes a new DatabaseFactory
-func New(dsn string, enableLogs bool, autoMigrateModels []any) *DatabaseFactory {
+func New(dsn string, enableLogs bool, autoMigrateModels []any, opts ...Option) *DatabaseFactory {
 	if autoMigrateModels == nil {
 		autoMigrateModels = []any{}
 	}
@@ -45,6 +66,7 @@ func New(dsn string, enableLogs bool, autoMigrateModels []any) *DatabaseFactory
 		dsn:               dsn,
 		enableLogs:        enableLogs,
 		autoMigrateModels: autoMigrateModels,
+		opts:              opts,
 	}
 }
 
@@ -52,7 +74,7 @@ func New(dsn string, enableLogs bool, autoMigrateModels []any) *DatabaseFactory
 func (f *DatabaseFactory) Create(dbType DatabaseType) (Database, error) {
 	switch dbType {
 	case PostgresDB:
-		return postgresql.NewPostgres(f.dsn, f.enableLogs, f.autoMigrateModels), nil
+		return postgresql.NewPostgres(f.dsn, f.enableLogs, f.autoMigrateModels, f.opts...), nil
 	default:
 		return nil, fmt.Errorf("unsupported database type: %v", dbType)
 	}
@@ -0,0 +1,88 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultSlowThreshold is the query duration above which queries are
+// logged as slow unless overridden with WithSlowThreshold.
+const DefaultSlowThreshold = 200 * time.Millisecond
+
+// slogLogger is a GORM logger that writes through
+// observability.LoggerFromContext, so query logs carry the request_id,
+// trace_id and tenant of the request that issued them.
+type slogLogger struct {
+	base          *slog.Logger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+func (l *slogLogger) LogMode(level logger.LogLevel) logger.Interface {
+	c := *l
+	c.level = level
+	return &c
+}
+
+func (l *slogLogger) log(ctx context.Context) *slog.Logger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return observability.LoggerFromContext(ctx, l.base)
+}
+
+func (l *slogLogger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Info {
+		l.log(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Warn {
+		l.log(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= logger.Error {
+		l.log(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *slogLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+	if !(failed && l.level >= logger.Error) && !(slow && l.level >= logger.Warn) && l.level < logger.Info {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []any{
+		slog.String("sql", sanitizeSQL(sql)),
+		slog.Int64("rows", rows),
+		slog.Duration("duration", elapsed),
+	}
+	if schema := schemaFromContext(ctx); schema != "" {
+		attrs = append(attrs, slog.String("schema", schema))
+	}
+	log := l.log(ctx)
+	switch {
+	case failed && l.level >= logger.Error:
+		log.ErrorContext(ctx, "database query failed", append(attrs, slog.String("error", err.Error()))...)
+	case slow && l.level >= logger.Warn:
+		log.WarnContext(ctx, "slow database query", append(attrs, slog.Duration("threshold", l.slowThreshold))...)
+	default:
+		log.InfoContext(ctx, "database query", attrs...)
+	}
+}
@@ -0,0 +1,35 @@
+package pg
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"gorm.io/gorm"
+)
+
+const metricsStartKey = "gossiper:metrics_start"
+
+// registerMetrics records the count, errors and duration of every GORM
+// operation through observability.RecordDBOperation.
+func registerMetrics(db *gorm.DB) error {
+	return registerAround(db, "gossiper:metrics_", func(string) func(*gorm.DB) { return startMetrics }, finishMetrics)
+}
+
+func startMetrics(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func finishMetrics(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		observability.RecordDBOperation(db.Statement.Context, dbSystem, operation, db.Statement.Table, v.(time.Time), err)
+	}
+}
@@ -0,0 +1,62 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"gorm.io/gorm"
+)
+
+func TestRegisterMetricsRecordsOperations(t *testing.T) {
+	reader := metricsdk.NewManualReader()
+	mp := metricsdk.NewMeterProvider(metricsdk.WithReader(reader))
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() { otel.SetMeterProvider(prev) })
+
+	db := openDryRun(t, nil)
+	ctx := context.Background()
+	db.WithContext(ctx).Create(&account{Name: "a"})
+	db.WithContext(ctx).Find(&[]account{})
+	db.WithContext(ctx).Find(&[]account{})
+	failing := db.WithContext(ctx).Session(&gorm.Session{})
+	failing.AddError(errors.New("boom"))
+	failing.Model(&account{}).Where("id = ?", 1).Update("name", "b")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	requests := sums(rm, "db.client.requests")
+	if got := requests["create"]; got != 1 {
+		t.Errorf("create requests = %d, want 1", got)
+	}
+	if got := requests["query"]; got != 2 {
+		t.Errorf("query requests = %d, want 2", got)
+	}
+	if got := sums(rm, "db.client.errors")["update"]; got != 1 {
+		t.Errorf("update errors = %d, want 1", got)
+	}
+}
+
+// sums returns the values of the named counter keyed by operation.
+func sums(rm metricdata.ResourceMetrics, name string) map[string]int64 {
+	out := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				op, _ := dp.Attributes.Value(attribute.Key("db.operation.name"))
+				out[op.AsString()] += dp.Value
+			}
+		}
+	}
+	return out
+}
@@ -7,7 +7,7 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"log"
-	"os"
+	"log/slog"
 	"reflect"
 	"time"
 
@@ -18,22 +18,43 @@ type Postgres struct {
 	db *gorm.DB
 }
 
-// NewPostgres initializes the Postgres instance with a configurable logger
-func NewPostgres(dsn string, enableLogs bool, autoMigrateEntities []any) *Postgres {
-	var newLogger logger.Interface
+// Option configures NewPostgres.
+type Option func(*config)
+
+type config struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+// WithLogger sets the base logger for query logs. Defaults to
+// slog.Default() at the time of each query.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithSlowThreshold sets the duration above which a query is logged as
+// slow. Zero disables slow-query logging. Defaults to DefaultSlowThreshold.
+func WithSlowThreshold(d time.Duration) Option {
+	return func(c *config) {
+		c.slowThreshold = d
+	}
+}
+
+// NewPostgres initializes the Postgres instance. Every query gets a client
+// span and RED metrics; with enableLogs, queries are also logged through
+// observability.LoggerFromContext, slow ones as warnings.
+func NewPostgres(dsn string, enableLogs bool, autoMigrateEntities []any, opts ...Option) *Postgres {
+	cfg := config{slowThreshold: DefaultSlowThreshold}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	level := logger.Silent
 	if enableLogs {
-		newLogger = logger.New(
-			log.New(os.Stdout, "\r\n", log.LstdFlags),
-			logger.Config{
-				SlowThreshold:             200 * time.Millisecond,
-				LogLevel:                  logger.Info,
-				IgnoreRecordNotFoundError: true,
-				Colorful:                  true,
-			},
-		)
-	} else {
-		newLogger = logger.Default.LogMode(logger.Silent)
+		level = logger.Info
 	}
+	newLogger := &slogLogger{base: cfg.logger, level: level, slowThreshold: cfg.slowThreshold}
 
 	// PreferSimpleProtocol disables server-side prepared statement caching.
 	// This connection pool is shared across every tenant, and SwitchSchema
@@ -55,6 +76,10 @@ func NewPostgres(dsn string, enableLogs bool, autoMigrateEntities []any) *Postgr
 		log.Fatalf("Failed to connect to PostgreSQL: %v", err)
 	}
 
+	if err := db.Use(observabilityPlugin{}); err != nil {
+		log.Fatalf("Failed to register database observability: %v", err)
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		log.Fatalf("Failed to get db instance: %v", err)
@@ -154,7 +179,7 @@ func (p *Postgres) WithSchema(ctx context.Context, schema string, fn func(tx *go
 	if err != nil {
 		return fmt.Errorf("failed to switch schema: %w", err)
 	}
-	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	return p.db.WithContext(withSchema(ctx, schema)).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Exec(fmt.Sprintf("SET search_path TO %s", quoted)).Error; err != nil {
 			return fmt.Errorf("failed to set search_path: %w", err)
 		}
@@ -162,6 +187,15 @@ func (p *Postgres) WithSchema(ctx context.Context, schema string, fn func(tx *go
 	})
 }
 
+// HealthCheck pings the database through the connection pool.
+func (p *Postgres) HealthCheck(ctx context.Context) error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
 func (p *Postgres) MigrateTenants(schemas []string, autoMigrateEntities []any) error {
 	ctx := context.Background()
 	for _, schema := range schemas {
@@ -0,0 +1,142 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	dbSystem      = "postgresql"
+	parentKey     = "gossiper:parent"
+	spanKey       = "gossiper:span"
+	pluginName    = "gossiper:observability"
+	callbackBasis = "gossiper:observability_"
+)
+
+type schemaKey struct{}
+
+// withSchema records the tenant schema a context's queries run against,
+// for span attributes.
+func withSchema(ctx context.Context, schema string) context.Context {
+	return context.WithValue(ctx, schemaKey{}, schema)
+}
+
+func schemaFromContext(ctx context.Context) string {
+	schema, _ := ctx.Value(schemaKey{}).(string)
+	return schema
+}
+
+// observabilityPlugin creates a client span and records RED metrics for
+// every GORM operation.
+type observabilityPlugin struct{}
+
+func (observabilityPlugin) Name() string { return pluginName }
+
+func (observabilityPlugin) Initialize(db *gorm.DB) error {
+	// Metrics go first so their after callback still sees the query span
+	// in the statement context.
+	if err := registerMetrics(db); err != nil {
+		return err
+	}
+	return registerAround(db, callbackBasis, startSpan, finishSpan)
+}
+
+// registerAround registers before(operation) and after(operation) around
+// each GORM operation, named prefix+"before_"+operation and
+// prefix+"after_"+operation.
+func registerAround(db *gorm.DB, prefix string, before, after func(operation string) func(*gorm.DB)) error {
+	cb := db.Callback()
+	processors := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, p := range processors {
+		if err := p.before(prefix+"before_"+p.operation, before(p.operation)); err != nil {
+			return err
+		}
+		if err := p.after(prefix+"after_"+p.operation, after(p.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSpan starts the operation's span and makes it the statement
+// context, so the driver and later callbacks run under it.
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		name := operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		spanCtx, span := otel.Tracer("gossiper/db").Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+		db.InstanceSet(parentKey, ctx)
+		db.InstanceSet(spanKey, span)
+		db.Statement.Context = spanCtx
+	}
+}
+
+// finishSpan ends the span started by startSpan and restores the parent
+// context, so the next statement on a reused session is not its child.
+func finishSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span := v.(trace.Span)
+		attrs := []attribute.KeyValue{
+			attribute.String("db.system.name", dbSystem),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", sanitizeSQL(db.Statement.SQL.String())),
+			attribute.Int64("db.response.rows_affected", db.Statement.RowsAffected),
+		}
+		if db.Statement.Table != "" {
+			attrs = append(attrs, attribute.String("db.collection.name", db.Statement.Table))
+		}
+		if schema := schemaFromContext(db.Statement.Context); schema != "" {
+			attrs = append(attrs, attribute.String("db.tenant.schema", schema))
+		}
+		span.SetAttributes(attrs...)
+		if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		if parent, ok := db.InstanceGet(parentKey); ok {
+			db.Statement.Context = parent.(context.Context)
+		}
+	}
+}
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteral = regexp.MustCompile(`(^|[^\w$.])-?\d+(?:\.\d+)?\b`)
+)
+
+// sanitizeSQL replaces string and numeric literals with ?, so values
+// written inline (by Raw, Exec or the logger's explained SQL) never reach
+// spans or logs. Bind placeholders such as $1 are kept.
+func sanitizeSQL(sql string) string {
+	sql = stringLiteral.ReplaceAllString(sql, "?")
+	return numericLiteral.ReplaceAllString(sql, "${1}?")
+}
@@ -0,0 +1,153 @@
+package pg
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type account struct {
+	ID   uint
+	Name string
+}
+
+// openDryRun opens a database that builds SQL without a server but still
+// runs every callback and the logger.
+func openDryRun(t *testing.T, l logger.Interface) *gorm.DB {
+	t.Helper()
+	if l == nil {
+		l = logger.Default.LogMode(logger.Silent)
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               l,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := db.Use(observabilityPlugin{}); err != nil {
+		t.Fatalf("use plugin: %v", err)
+	}
+	return db
+}
+
+func TestPluginCreatesChildSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	db := openDryRun(t, nil)
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	db.WithContext(withSchema(ctx, "tenant_a")).Where("name = ?", "alice").Find(&[]account{})
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "query accounts" {
+		t.Errorf("span name = %q", span.Name)
+	}
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("query span is not a child of the request span")
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range span.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if got := attrs["db.collection.name"].AsString(); got != "accounts" {
+		t.Errorf("db.collection.name = %q", got)
+	}
+	if got := attrs["db.tenant.schema"].AsString(); got != "tenant_a" {
+		t.Errorf("db.tenant.schema = %q", got)
+	}
+	if got := attrs["db.query.text"].AsString(); strings.Contains(got, "alice") || !strings.Contains(got, "$1") {
+		t.Errorf("db.query.text = %q", got)
+	}
+}
+
+func TestPluginPropagatesSpanContext(t *testing.T) {
+	tp := tracesdk.NewTracerProvider()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	db := openDryRun(t, nil)
+	var during trace.SpanContext
+	err := db.Callback().Query().Before("gorm:query").After(callbackBasis+"before_query").
+		Register("test:capture", func(db *gorm.DB) {
+			during = trace.SpanContextFromContext(db.Statement.Context)
+		})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "request")
+	defer parent.End()
+	tx := db.WithContext(ctx).Find(&[]account{})
+
+	if !during.IsValid() || during.SpanID() == parent.SpanContext().SpanID() {
+		t.Errorf("statement context during the query does not carry the query span")
+	}
+	if got := trace.SpanContextFromContext(tx.Statement.Context); got.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("statement context after the query is not restored to the request span")
+	}
+}
+
+func TestLoggerRoutesThroughLoggerFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewJSONHandler(&buf, nil))
+	db := openDryRun(t, &slogLogger{base: base, level: logger.Warn, slowThreshold: time.Nanosecond})
+
+	ctx := observability.WithRequestData(context.Background(), "req-1", "", "")
+	db.WithContext(ctx).Where("name = ?", "alice").Find(&[]account{})
+
+	out := buf.String()
+	for _, want := range []string{`"msg":"slow database query"`, `"request_id":"req-1"`, `FROM \"accounts\"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log has no %s: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "alice") {
+		t.Errorf("log contains a query value: %s", out)
+	}
+
+	buf.Reset()
+	quiet := openDryRun(t, &slogLogger{base: base, level: logger.Warn, slowThreshold: time.Hour})
+	quiet.WithContext(ctx).Find(&[]account{})
+	if buf.Len() != 0 {
+		t.Errorf("fast query logged at warn level: %s", buf.String())
+	}
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`SELECT * FROM "users" WHERE name = 'o''brien' AND age > 42`, `SELECT * FROM "users" WHERE name = ? AND age > ?`},
+		{`SELECT * FROM "t1" WHERE id = $1 LIMIT 10`, `SELECT * FROM "t1" WHERE id = $1 LIMIT ?`},
+		{`UPDATE "accounts" SET "balance"=-3.5 WHERE "id" = 7`, `UPDATE "accounts" SET "balance"=? WHERE "id" = ?`},
+	}
+	for _, tt := range tests {
+		if got := sanitizeSQL(tt.in); got != tt.want {
+			t.Errorf("sanitizeSQL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCheckTimeout bounds a single check when the caller's context has
+// no earlier deadline, so one hung dependency cannot stall a probe.
+const DefaultCheckTimeout = 2 * time.Second
+
+// Check reports whether a dependency is usable. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+// Checker is implemented by dependencies that can report their own health
+// (databases, transports, the tenant manager, ...).
+type Checker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Report is the result of evaluating readiness.
+type Report struct {
+	Ready    bool              `json:"ready"`
+	Draining bool              `json:"draining,omitempty"`
+	Checks   map[string]string `json:"checks,omitempty"`
+}
+
+// Registry collects named readiness checks. Liveness is deliberately not
+// tied to dependencies: a process whose database is down should be taken
+// out of rotation, not restarted.
+type Registry struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	draining atomic.Bool
+	drained  chan struct{} // closed while draining
+}
+
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check), drained: make(chan struct{})}
+}
+
+// Register adds or replaces the readiness check stored under name.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// SetDraining marks the process as shutting down. While draining,
+// readiness reports not ready regardless of the registered checks so load
+// balancers stop routing new traffic.
+func (r *Registry) SetDraining(draining bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.draining.Swap(draining) == draining {
+		return
+	}
+	if draining {
+		close(r.drained)
+	} else {
+		r.drained = make(chan struct{})
+	}
+}
+
+// DrainingC returns a channel that is closed once SetDraining(true) is
+// in effect, so long-lived watchers can react without polling.
+func (r *Registry) DrainingC() <-chan struct{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.drained
+}
+
+// Draining reports whether SetDraining(true) is in effect.
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Names returns the registered check names in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckOne runs a single named check. found is false when no check is
+// registered under name.
+func (r *Registry) CheckOne(ctx context.Context, name string) (found bool, err error) {
+	r.mu.RLock()
+	check, ok := r.checks[name]
+	r.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return true, run(ctx, check)
+}
+
+// Ready runs every registered check concurrently and reports readiness.
+func (r *Registry) Ready(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	report := Report{Ready: true, Draining: r.Draining()}
+	if report.Draining {
+		report.Ready = false
+	}
+	if len(checks) == 0 {
+		return report
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			results <- result{name: name, err: run(ctx, check)}
+		}(name, check)
+	}
+
+	report.Checks = make(map[string]string, len(checks))
+	for range checks {
+		res := <-results
+		if res.err != nil {
+			report.Ready = false
+			report.Checks[res.name] = res.err.Error()
+			continue
+		}
+		report.Checks[res.name] = "ok"
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultCheckTimeout)
+	defer cancel()
+	return check(ctx)
+}
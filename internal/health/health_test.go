@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistry_ReadyWithNoChecks(t *testing.T) {
+	r := NewRegistry()
+	if report := r.Ready(context.Background()); !report.Ready {
+		t.Fatalf("expected ready with no checks, got %+v", report)
+	}
+}
+
+func TestRegistry_ReadyReportsFailingCheck(t *testing.T) {
+	r := NewRegistry()
+	r.Register("postgres", func(context.Context) error { return nil })
+	r.Register("billing", func(context.Context) error { return errors.New("unavailable") })
+
+	report := r.Ready(context.Background())
+	if report.Ready {
+		t.Fatal("expected not ready when a check fails")
+	}
+	if report.Checks["postgres"] != "ok" {
+		t.Errorf("postgres = %q, want ok", report.Checks["postgres"])
+	}
+	if report.Checks["billing"] != "unavailable" {
+		t.Errorf("billing = %q, want unavailable", report.Checks["billing"])
+	}
+}
+
+func TestRegistry_DrainingIsNotReady(t *testing.T) {
+	r := NewRegistry()
+	r.Register("postgres", func(context.Context) error { return nil })
+	r.SetDraining(true)
+
+	report := r.Ready(context.Background())
+	if report.Ready || !report.Draining {
+		t.Fatalf("expected draining and not ready, got %+v", report)
+	}
+}
+
+func TestRegistry_CheckTimesOut(t *testing.T) {
+	r := NewRegistry()
+	r.Register("stuck", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	found, err := r.CheckOne(ctx, "stuck")
+	if !found || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CheckOne = (%v, %v), want (true, deadline exceeded)", found, err)
+	}
+	if found, _ := r.CheckOne(ctx, "missing"); found {
+		t.Fatal("expected unknown check to be reported as not found")
+	}
+}
+
+func TestRegistry_DrainingCClosesWhileDraining(t *testing.T) {
+	r := NewRegistry()
+	select {
+	case <-r.DrainingC():
+		t.Fatal("DrainingC closed before draining")
+	default:
+	}
+	r.SetDraining(true)
+	r.SetDraining(true)
+	select {
+	case <-r.DrainingC():
+	default:
+		t.Fatal("DrainingC open while draining")
+	}
+	r.SetDraining(false)
+	select {
+	case <-r.DrainingC():
+		t.Fatal("DrainingC closed after draining was cleared")
+	default:
+	}
+}
@@ -0,0 +1,69 @@
+// Package inprocess connects gRPC clients to servers in the same process
+// over in-memory listeners, without opening any ports.
+package inprocess
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1 << 20
+
+var (
+	mu        sync.RWMutex
+	listeners = make(map[string]*bufconn.Listener)
+)
+
+// Serve serves server on an in-memory listener registered under name. It
+// returns once the listener is registered; stop unregisters and closes the
+// listener without stopping server.
+func Serve(name string, server *grpc.Server) (stop func(), err error) {
+	lis := bufconn.Listen(bufSize)
+	mu.Lock()
+	if _, ok := listeners[name]; ok {
+		mu.Unlock()
+		return nil, fmt.Errorf("in-process server %q is already registered", name)
+	}
+	listeners[name] = lis
+	mu.Unlock()
+
+	go func() {
+		_ = server.Serve(lis)
+		unregister(name, lis)
+	}()
+	return func() {
+		unregister(name, lis)
+		_ = lis.Close()
+	}, nil
+}
+
+func unregister(name string, lis *bufconn.Listener) {
+	mu.Lock()
+	defer mu.Unlock()
+	if listeners[name] == lis {
+		delete(listeners, name)
+	}
+}
+
+// Dial connects to the server registered under address. An address of the
+// form host:port also matches a server registered under its port, so the
+// address of a networked transport works unchanged.
+func Dial(ctx context.Context, address string) (net.Conn, error) {
+	mu.RLock()
+	lis, ok := listeners[address]
+	if !ok {
+		if _, port, err := net.SplitHostPort(address); err == nil {
+			lis, ok = listeners[port]
+		}
+	}
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no in-process server registered for %q", address)
+	}
+	return lis.DialContext(ctx)
+}
@@ -0,0 +1,100 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// AttributeTenant is the request attribute carrying the tenant. It is
+// always propagated.
+const AttributeTenant = "tenant"
+
+// requestAttributes holds the keys declared by Config.RequestAttributes,
+// after AttributeTenant.
+var requestAttributes atomic.Pointer[[]string]
+
+func init() {
+	requestAttributes.Store(&[]string{AttributeTenant})
+}
+
+// setRequestAttributes declares the propagated request attributes. It
+// rejects keys that are not valid W3C baggage keys.
+func setRequestAttributes(keys []string) error {
+	declared := []string{AttributeTenant}
+	for _, key := range keys {
+		if _, err := baggage.NewMember(key, ""); err != nil {
+			return fmt.Errorf("observability: invalid request attribute %q: %w", key, err)
+		}
+		if !slices.Contains(declared, key) {
+			declared = append(declared, key)
+		}
+	}
+	requestAttributes.Store(&declared)
+	return nil
+}
+
+// RequestAttributeKeys returns the declared request attributes, starting
+// with AttributeTenant.
+func RequestAttributeKeys() []string {
+	return slices.Clone(*requestAttributes.Load())
+}
+
+// WithRequestAttribute sets a request attribute on ctx. It travels as W3C
+// baggage to every service the request reaches, and declared attributes
+// are logged by LoggerFromContext and set on spans. An empty value removes
+// the attribute; invalid keys are ignored.
+func WithRequestAttribute(ctx context.Context, key, value string) context.Context {
+	b := baggage.FromContext(ctx)
+	if value == "" {
+		return baggage.ContextWithBaggage(ctx, b.DeleteMember(key))
+	}
+	m, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx
+	}
+	b, err = b.SetMember(m)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// RequestAttribute returns a request attribute set on ctx by this service
+// or an upstream one, or "".
+func RequestAttribute(ctx context.Context, key string) string {
+	return baggage.FromContext(ctx).Member(key).Value()
+}
+
+// declaredAttributes returns the declared request attributes set on ctx.
+func declaredAttributes(ctx context.Context) []attribute.KeyValue {
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	for _, key := range *requestAttributes.Load() {
+		if v := b.Member(key).Value(); v != "" {
+			attrs = append(attrs, attribute.String(key, v))
+		}
+	}
+	return attrs
+}
+
+// requestAttributeProcessor sets the declared request attributes of the
+// parent context on every span, so database and client spans carry them
+// too.
+type requestAttributeProcessor struct{}
+
+func (requestAttributeProcessor) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	s.SetAttributes(declaredAttributes(parent)...)
+}
+
+func (requestAttributeProcessor) OnEnd(tracesdk.ReadOnlySpan)      {}
+func (requestAttributeProcessor) Shutdown(context.Context) error   { return nil }
+func (requestAttributeProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,147 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// declareForTest declares request attributes and installs the propagator
+// of Init, restoring both afterwards.
+func declareForTest(t *testing.T, keys ...string) {
+	t.Helper()
+	prevKeys, prevProp := RequestAttributeKeys(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		_ = setRequestAttributes(prevKeys)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	if err := setRequestAttributes(keys); err != nil {
+		t.Fatal(err)
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+}
+
+func TestSetRequestAttributesRejectsInvalidKeys(t *testing.T) {
+	declareForTest(t)
+	if err := setRequestAttributes([]string{"feature flag"}); err == nil {
+		t.Error("expected an error for a key with a space")
+	}
+}
+
+func TestLoggerFromContextRequestAttributes(t *testing.T) {
+	declareForTest(t, "locale")
+
+	ctx := WithRequestAttribute(context.Background(), "locale", "de-DE")
+	ctx = WithRequestAttribute(ctx, "undeclared", "x")
+	ctx = WithRequestData(ctx, "req-1", "acme", "")
+
+	var buf bytes.Buffer
+	LoggerFromContext(ctx, slog.New(slog.NewJSONHandler(&buf, nil))).Info("hello")
+	out := buf.String()
+	for _, want := range []string{`"locale":"de-DE"`, `"tenant":"acme"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log has no %s: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "undeclared") {
+		t.Errorf("undeclared attribute logged: %s", out)
+	}
+	if strings.Count(out, `"tenant"`) != 1 {
+		t.Errorf("tenant logged more than once: %s", out)
+	}
+}
+
+func TestRequestAttributesCrossFiberAndGRPC(t *testing.T) {
+	declareForTest(t, "locale", "cohort")
+
+	spans := tracetest.NewSpanRecorder()
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithSpanProcessor(requestAttributeProcessor{}),
+		tracesdk.WithSpanProcessor(spans),
+	)
+	tracer := tp.Tracer("test")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	// The HTTP service sets the cohort and calls a gRPC service.
+	var outgoing metadata.MD
+	app := fiber.New()
+	app.Use(FiberMiddleware(logger, tracer))
+	app.Get("/", func(c *fiber.Ctx) error {
+		ctx := WithRequestAttribute(c.UserContext(), "cohort", "beta")
+		outgoing, _ = metadata.FromOutgoingContext(WithOutgoingMetadata(ctx))
+		return nil
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Namespace", "acme")
+	req.Header.Set("Baggage", "locale=de-DE,other=x")
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	// The gRPC service only sees the metadata.
+	incoming := metadata.MD{"baggage": outgoing.Get("baggage")}
+	var handled context.Context
+	interceptor := GRPCServerInterceptor(logger, tracer)
+	_, err = interceptor(metadata.NewIncomingContext(context.Background(), incoming), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/svc.Svc/Call"},
+		func(ctx context.Context, _ any) (any, error) {
+			handled = ctx
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"tenant": "acme", "locale": "de-DE", "cohort": "beta"}
+	for key, value := range want {
+		if got := RequestAttribute(handled, key); got != value {
+			t.Errorf("gRPC request attribute %s = %q, want %q", key, got, value)
+		}
+	}
+	if got := Tenant(handled); got != "acme" {
+		t.Errorf("gRPC tenant = %q, want acme", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	grpcLog := lines[len(lines)-1]
+	for key, value := range want {
+		if !strings.Contains(grpcLog, `"`+key+`":"`+value+`"`) {
+			t.Errorf("gRPC log has no %s: %s", key, grpcLog)
+		}
+	}
+	if strings.Contains(grpcLog, "other") {
+		t.Errorf("undeclared baggage logged: %s", grpcLog)
+	}
+
+	ended := spans.Ended()
+	grpcSpan := ended[len(ended)-1]
+	got := map[string]string{}
+	for _, kv := range grpcSpan.Attributes() {
+		got[string(kv.Key)] = kv.Value.AsString()
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("span attribute %s = %q, want %q", key, got[key], value)
+		}
+	}
+	if _, ok := got["other"]; ok {
+		t.Error("undeclared baggage set on span")
+	}
+}
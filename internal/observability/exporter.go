@@ -0,0 +1,158 @@
+package observability
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// Exporter selects where Init sends spans.
+type Exporter string
+
+const (
+	// ExporterOTLP sends spans to an OTLP collector at Config.OtlpEndpoint.
+	// This is the default.
+	ExporterOTLP Exporter = "otlp"
+	// ExporterStdout writes spans as JSON to stdout, for local development.
+	ExporterStdout Exporter = "stdout"
+	// ExporterFile writes spans as JSON to Config.ExportFile.
+	ExporterFile Exporter = "file"
+	// ExporterNone records spans without exporting them: trace and span
+	// IDs still reach the logs, but nothing leaves the process.
+	ExporterNone Exporter = "none"
+)
+
+// Protocol is the OTLP transport protocol.
+type Protocol string
+
+const (
+	// ProtocolHTTP is OTLP over HTTP with protobuf payloads (port 4318).
+	// This is the default.
+	ProtocolHTTP Protocol = "http/protobuf"
+	// ProtocolGRPC is OTLP over gRPC (port 4317).
+	ProtocolGRPC Protocol = "grpc"
+)
+
+// Compression is the OTLP payload compression.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+)
+
+// newTraceExporter builds the span exporter selected by cfg. It returns a
+// nil exporter for ExporterNone, and a closer that releases any file it
+// opened.
+func newTraceExporter(ctx context.Context, cfg Config) (tracesdk.SpanExporter, func() error, error) {
+	noClose := func() error { return nil }
+	switch cfg.Exporter {
+	case "", ExporterOTLP:
+		exporter, err := newOTLPExporter(ctx, cfg)
+		return exporter, noClose, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exporter, noClose, err
+	case ExporterFile:
+		if cfg.ExportFile == "" {
+			return nil, nil, fmt.Errorf("observability: exporter %q needs ExportFile", cfg.Exporter)
+		}
+		f, err := os.OpenFile(cfg.ExportFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(io.Writer(f)))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f.Close, nil
+	case ExporterNone:
+		return nil, noClose, nil
+	default:
+		return nil, nil, fmt.Errorf("observability: unknown exporter %q", cfg.Exporter)
+	}
+}
+
+// otlpTarget returns the collector endpoint of cfg and the TLS
+// configuration to reach it, nil for plaintext.
+func otlpTarget(cfg Config) (otlpEndpoint, *tls.Config) {
+	endpoint := parseOTLPEndpoint(cfg.OtlpEndpoint)
+	tlsConfig := cfg.OtlpTLS
+	if tlsConfig == nil && endpoint.secure {
+		tlsConfig = &tls.Config{}
+	}
+	return endpoint, tlsConfig
+}
+
+func newOTLPExporter(ctx context.Context, cfg Config) (tracesdk.SpanExporter, error) {
+	endpoint, tlsConfig := otlpTarget(cfg)
+
+	switch cfg.OtlpProtocol {
+	case "", ProtocolHTTP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.host)}
+		if endpoint.path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(endpoint.path))
+		}
+		if tlsConfig != nil {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConfig))
+		} else {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.OtlpHeaders) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.OtlpHeaders))
+		}
+		if cfg.OtlpCompression == CompressionGzip {
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case ProtocolGRPC:
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint.host)}
+		if tlsConfig != nil {
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		} else {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.OtlpHeaders) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.OtlpHeaders))
+		}
+		if cfg.OtlpCompression == CompressionGzip {
+			opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("observability: unknown OTLP protocol %q", cfg.OtlpProtocol)
+	}
+}
+
+type otlpEndpoint struct {
+	host   string
+	path   string
+	secure bool
+}
+
+// parseOTLPEndpoint accepts "host:port" or a URL. An https scheme enables
+// TLS and a path other than "/" overrides the default /v1/traces.
+func parseOTLPEndpoint(endpoint string) otlpEndpoint {
+	endpoint = strings.TrimSpace(endpoint)
+	if strings.Contains(endpoint, "://") {
+		if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+			path := parsed.Path
+			if path == "/" {
+				path = ""
+			}
+			return otlpEndpoint{host: parsed.Host, path: path, secure: parsed.Scheme == "https"}
+		}
+	}
+	return otlpEndpoint{host: strings.TrimSuffix(endpoint, "/")}
+}
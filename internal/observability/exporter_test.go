@@ -0,0 +1,142 @@
+package observability
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	logglobal "go.opentelemetry.io/otel/log/global"
+)
+
+func TestParseOTLPEndpoint(t *testing.T) {
+	tests := []struct {
+		in   string
+		want otlpEndpoint
+	}{
+		{"collector:4318", otlpEndpoint{host: "collector:4318"}},
+		{"http://collector:4318/", otlpEndpoint{host: "collector:4318"}},
+		{"https://collector:4318", otlpEndpoint{host: "collector:4318", secure: true}},
+		{"https://gateway.example.com/otlp/v1/traces", otlpEndpoint{host: "gateway.example.com", path: "/otlp/v1/traces", secure: true}},
+	}
+	for _, tt := range tests {
+		if got := parseOTLPEndpoint(tt.in); got != tt.want {
+			t.Errorf("parseOTLPEndpoint(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// initForTest runs Init and restores the global state it replaces.
+func initForTest(t *testing.T, cfg Config) (func(context.Context) error, error) {
+	t.Helper()
+	prevLogger, prevTP, prevMP := slog.Default(), otel.GetTracerProvider(), otel.GetMeterProvider()
+	prevLP := logglobal.GetLoggerProvider()
+	t.Cleanup(func() {
+		slog.SetDefault(prevLogger)
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		logglobal.SetLoggerProvider(prevLP)
+	})
+	logger, tracer, shutdown, err := Init(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if logger == nil || tracer == nil {
+		t.Fatal("Init returned a nil logger or tracer")
+	}
+	_, span := tracer.Start(context.Background(), "work")
+	span.End()
+	return shutdown, nil
+}
+
+func TestInitWithoutExporter(t *testing.T) {
+	shutdown, err := initForTest(t, Config{ServiceName: "svc", Exporter: ExporterNone, SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
+
+func TestInitFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := initForTest(t, Config{ServiceName: "svc", Exporter: ExporterFile, ExportFile: path, SampleRatio: 1})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"Name":"work"`) {
+		t.Errorf("span not written to file: %s", data)
+	}
+
+	if _, err := initForTest(t, Config{Exporter: ExporterFile}); err == nil {
+		t.Error("file exporter without ExportFile succeeded")
+	}
+}
+
+func TestInitOTLPHTTPWithTLSHeadersAndGzip(t *testing.T) {
+	type export struct{ path, auth, encoding string }
+	got := make(chan export, 1)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case got <- export{r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Content-Encoding")}:
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	shutdown, err := initForTest(t, Config{
+		ServiceName:     "svc",
+		OtlpEndpoint:    srv.URL,
+		OtlpTLS:         &tls.Config{RootCAs: roots},
+		OtlpHeaders:     map[string]string{"Authorization": "Bearer collector-token"},
+		OtlpCompression: CompressionGzip,
+		SampleRatio:     1,
+	})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	select {
+	case e := <-got:
+		want := export{"/v1/traces", "Bearer collector-token", "gzip"}
+		if e != want {
+			t.Errorf("export = %+v, want %+v", e, want)
+		}
+	default:
+		t.Fatal("collector received no export")
+	}
+}
+
+func TestNewTraceExporterRejectsUnknownSettings(t *testing.T) {
+	if _, _, err := newTraceExporter(context.Background(), Config{Exporter: "kafka"}); err == nil {
+		t.Error("unknown exporter accepted")
+	}
+	if _, _, err := newTraceExporter(context.Background(), Config{OtlpProtocol: "thrift"}); err == nil {
+		t.Error("unknown protocol accepted")
+	}
+	exporter, _, err := newTraceExporter(context.Background(), Config{OtlpProtocol: ProtocolGRPC, OtlpEndpoint: "collector:4317"})
+	if err != nil || exporter == nil {
+		t.Fatalf("gRPC exporter = %v, %v", exporter, err)
+	}
+	_ = exporter.Shutdown(context.Background())
+}
@@ -0,0 +1,108 @@
+package observability
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// Credentials is what an IdentityExtractor can inspect about a request.
+type Credentials struct {
+	// Header returns the first value of a request header or metadata key,
+	// looked up case-insensitively, or "".
+	Header func(key string) string
+	// TLS is the connection state when the request arrived over TLS.
+	TLS *tls.ConnectionState
+}
+
+func (c Credentials) header(key string) string {
+	if c.Header == nil {
+		return ""
+	}
+	return c.Header(key)
+}
+
+// IdentityExtractor returns the caller identity logged as the user field,
+// or "" when the request carries no identity it understands. Extractors
+// must never return secrets: the result appears in every log line.
+type IdentityExtractor func(c Credentials) string
+
+// DefaultIdentityExtractor logs the JWT subject of a bearer token, falling
+// back to the common name of an mTLS client certificate.
+var DefaultIdentityExtractor = FirstIdentity(JWTSubject(), MTLSCommonName())
+
+// FirstIdentity returns the identity of the first extractor that finds one.
+func FirstIdentity(extractors ...IdentityExtractor) IdentityExtractor {
+	return func(c Credentials) string {
+		for _, e := range extractors {
+			if id := e(c); id != "" {
+				return id
+			}
+		}
+		return ""
+	}
+}
+
+// JWTSubject returns the "sub" claim of a bearer token in the
+// Authorization header. The token is decoded, not verified, so use the
+// result for logging and correlation only; authentication belongs in the
+// auth stage.
+func JWTSubject() IdentityExtractor {
+	return JWTClaim("sub")
+}
+
+// JWTClaim returns a string claim of a bearer token in the Authorization
+// header. Like JWTSubject it does not verify the token.
+func JWTClaim(claim string) IdentityExtractor {
+	return func(c Credentials) string {
+		scheme, token, ok := strings.Cut(c.header("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		parts := strings.Split(strings.TrimSpace(token), ".")
+		if len(parts) != 3 {
+			return ""
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return ""
+		}
+		var claims map[string]any
+		if json.Unmarshal(payload, &claims) != nil {
+			return ""
+		}
+		v, _ := claims[claim].(string)
+		return v
+	}
+}
+
+// APIKeyID identifies callers by the API key sent in header. Keys of the
+// form "<id>.<secret>" are logged by their id; other keys by a short
+// SHA-256 fingerprint, so the key itself never reaches the logs.
+func APIKeyID(header string) IdentityExtractor {
+	return func(c Credentials) string {
+		key := strings.TrimSpace(c.header(header))
+		if key == "" {
+			return ""
+		}
+		if id, _, ok := strings.Cut(key, "."); ok && id != "" {
+			return "apikey:" + id
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "apikey:sha256:" + hex.EncodeToString(sum[:6])
+	}
+}
+
+// MTLSCommonName returns the subject common name of the verified client
+// certificate. Certificates the server did not verify are ignored.
+func MTLSCommonName() IdentityExtractor {
+	return func(c Credentials) string {
+		if c.TLS == nil || len(c.TLS.VerifiedChains) == 0 || len(c.TLS.VerifiedChains[0]) == 0 {
+			return ""
+		}
+		return c.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+}
@@ -0,0 +1,163 @@
+package observability
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func testJWT(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"HS256"}`)) + "." + enc([]byte(payload)) + ".c2ln"
+}
+
+func headers(kv ...string) func(string) string {
+	return func(key string) string {
+		for i := 0; i+1 < len(kv); i += 2 {
+			if strings.EqualFold(kv[i], key) {
+				return kv[i+1]
+			}
+		}
+		return ""
+	}
+}
+
+func TestIdentityExtractors(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "billing.internal"}},
+	}}}
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+		{Subject: pkix.Name{CommonName: "mallory"}},
+	}}
+
+	tests := []struct {
+		name      string
+		extractor IdentityExtractor
+		creds     Credentials
+		want      string
+	}{
+		{"jwt subject", JWTSubject(), Credentials{Header: headers("Authorization", "Bearer "+testJWT(`{"sub":"user-42"}`))}, "user-42"},
+		{"jwt without sub", JWTSubject(), Credentials{Header: headers("Authorization", "Bearer "+testJWT(`{"iss":"x"}`))}, ""},
+		{"opaque bearer token", JWTSubject(), Credentials{Header: headers("Authorization", "Bearer s3cr3t")}, ""},
+		{"basic auth", JWTSubject(), Credentials{Header: headers("Authorization", "Basic dXNlcjpwYXNz")}, ""},
+		{"no headers", JWTSubject(), Credentials{}, ""},
+		{"api key id", APIKeyID("X-Api-Key"), Credentials{Header: headers("x-api-key", "k_123.topsecret")}, "apikey:k_123"},
+		{"api key fingerprint", APIKeyID("X-Api-Key"), Credentials{Header: headers("X-Api-Key", "topsecret")}, "apikey:sha256:53336a676c64"},
+		{"mtls common name", MTLSCommonName(), Credentials{TLS: verified}, "billing.internal"},
+		{"unverified certificate", MTLSCommonName(), Credentials{TLS: unverified}, ""},
+		{"default falls back to mtls", DefaultIdentityExtractor, Credentials{Header: headers("Authorization", "Bearer s3cr3t"), TLS: verified}, "billing.internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.extractor(tt.creds); got != tt.want {
+				t.Errorf("identity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFiberMiddlewareNeverLogsAuthorization(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	app := fiber.New()
+	app.Use(FiberMiddleware(logger, noop.NewTracerProvider().Tracer("test")))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	tests := []struct {
+		token    string
+		wantUser string
+	}{
+		{testJWT(`{"sub":"user-42"}`), `"user":"user-42"`},
+		{"opaque-secret", ""},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		res.Body.Close()
+		out := buf.String()
+		if strings.Contains(out, tt.token) {
+			t.Errorf("log contains the token: %s", out)
+		}
+		if tt.wantUser != "" && !strings.Contains(out, tt.wantUser) {
+			t.Errorf("log has no %s: %s", tt.wantUser, out)
+		}
+	}
+}
+
+func TestGRPCServerInterceptorIdentity(t *testing.T) {
+	verified := tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "orders.internal"}},
+	}}}
+	tlsPeer := &peer.Peer{AuthInfo: credentials.TLSInfo{State: verified}}
+
+	tests := []struct {
+		name       string
+		md         metadata.MD
+		peer       *peer.Peer
+		opts       []MiddlewareOption
+		wantTenant string
+		wantUser   string
+	}{
+		{"propagated user ignored by default", metadata.Pairs("namespace", "acme", "x-user-id", "user-7"), nil, nil, "acme", ""},
+		{"jwt in metadata", metadata.Pairs("authorization", "Bearer "+testJWT(`{"sub":"user-42"}`)), nil, nil, "", "user-42"},
+		{"mtls peer", metadata.MD{}, tlsPeer, nil, "", "orders.internal"},
+		{"peer wins over spoofed user", metadata.Pairs("x-user-id", "user-7"), tlsPeer, nil, "", "orders.internal"},
+		{"trusted propagation", metadata.Pairs("x-user-id", "user-7"), tlsPeer,
+			[]MiddlewareOption{WithTrustedUserPropagation()}, "", "user-7"},
+		{"custom keys", metadata.Pairs("x-tenant", "globex", "x-principal", "svc-a", "namespace", "ignored"), nil,
+			[]MiddlewareOption{WithTenantKey("X-Tenant"), WithUserKey("x-principal"), WithTrustedUserPropagation()}, "globex", "svc-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			if tt.peer != nil {
+				ctx = peer.NewContext(ctx, tt.peer)
+			}
+			var handled context.Context
+			interceptor := GRPCServerInterceptor(slog.New(slog.DiscardHandler), noop.NewTracerProvider().Tracer("test"), tt.opts...)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc.Svc/Call"}, func(ctx context.Context, _ any) (any, error) {
+				handled = ctx
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Tenant(handled); got != tt.wantTenant {
+				t.Errorf("tenant = %q, want %q", got, tt.wantTenant)
+			}
+			if got := User(handled); got != tt.wantUser {
+				t.Errorf("user = %q, want %q", got, tt.wantUser)
+			}
+
+			// Downstream calls carry the same values under the same keys.
+			keys := newMiddlewareConfig(tt.opts).keys
+			out, _ := metadata.FromOutgoingContext(WithOutgoingMetadata(handled))
+			if got := firstFromMD(out, keys.tenant); got != tt.wantTenant {
+				t.Errorf("outgoing %s = %q, want %q", keys.tenant, got, tt.wantTenant)
+			}
+			if got := firstFromMD(out, keys.user); got != tt.wantUser {
+				t.Errorf("outgoing %s = %q, want %q", keys.user, got, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/credentials"
+)
+
+// newLoggerProvider creates a LoggerProvider that batches records to the
+// OTLP collector configured for traces.
+func newLoggerProvider(ctx context.Context, cfg Config, res *resource.Resource) (*sdklog.LoggerProvider, error) {
+	exporter, err := newOTLPLogExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(res),
+	), nil
+}
+
+func newOTLPLogExporter(ctx context.Context, cfg Config) (sdklog.Exporter, error) {
+	endpoint, tlsConfig := otlpTarget(cfg)
+
+	switch cfg.OtlpProtocol {
+	case "", ProtocolHTTP:
+		opts := []otlploghttp.Option{otlploghttp.WithEndpoint(endpoint.host)}
+		// A custom traces path, such as /otlp/v1/traces, has a logs sibling.
+		if prefix, ok := strings.CutSuffix(endpoint.path, "/v1/traces"); ok {
+			opts = append(opts, otlploghttp.WithURLPath(prefix+"/v1/logs"))
+		}
+		if tlsConfig != nil {
+			opts = append(opts, otlploghttp.WithTLSClientConfig(tlsConfig))
+		} else {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		if len(cfg.OtlpHeaders) > 0 {
+			opts = append(opts, otlploghttp.WithHeaders(cfg.OtlpHeaders))
+		}
+		if cfg.OtlpCompression == CompressionGzip {
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		}
+		return otlploghttp.New(ctx, opts...)
+	case ProtocolGRPC:
+		opts := []otlploggrpc.Option{otlploggrpc.WithEndpoint(endpoint.host)}
+		if tlsConfig != nil {
+			opts = append(opts, otlploggrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		} else {
+			opts = append(opts, otlploggrpc.WithInsecure())
+		}
+		if len(cfg.OtlpHeaders) > 0 {
+			opts = append(opts, otlploggrpc.WithHeaders(cfg.OtlpHeaders))
+		}
+		if cfg.OtlpCompression == CompressionGzip {
+			opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+		}
+		return otlploggrpc.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("observability: unknown OTLP protocol %q", cfg.OtlpProtocol)
+	}
+}
+
+// newOTLPHandler bridges slog records at level or above to lp. Attributes
+// added with Logger.With, such as those of LoggerFromContext, become log
+// record attributes, and records logged with a context carry its trace.
+func newOTLPHandler(name string, lp *sdklog.LoggerProvider, level slog.Leveler) slog.Handler {
+	return &levelHandler{
+		Handler: otelslog.NewHandler(name, otelslog.WithLoggerProvider(lp), otelslog.WithSource(true)),
+		level:   level,
+	}
+}
+
+// levelHandler drops records below level.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.Handler.Enabled(ctx, level)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// fanoutHandler passes every record to each handler that accepts its
+// level.
+type fanoutHandler []slog.Handler
+
+// NewFanoutHandler returns a handler writing every record to all of
+// handlers, such as stdout JSON and an OTLP bridge.
+func NewFanoutHandler(handlers ...slog.Handler) slog.Handler {
+	return fanoutHandler(handlers)
+}
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// memoryLogExporter keeps exported log records in memory.
+type memoryLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryLogExporter) ForceFlush(context.Context) error { return nil }
+
+func TestFanoutHandlerBridgesToOTLP(t *testing.T) {
+	exporter := &memoryLogExporter{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	t.Cleanup(func() { _ = lp.Shutdown(context.Background()) })
+
+	var stdout strings.Builder
+	logger := slog.New(NewRedactHandler(NewFanoutHandler(
+		slog.NewJSONHandler(&stdout, nil),
+		newOTLPHandler("svc", lp, slog.LevelInfo),
+	), DefaultRedactedKeys...))
+
+	tp := tracesdk.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "work")
+	defer span.End()
+	ctx = WithRequestData(ctx, "req-1", "acme", "")
+
+	LoggerFromContext(ctx, logger).InfoContext(ctx, "order created", "password", "hunter2")
+	LoggerFromContext(ctx, logger).DebugContext(ctx, "dropped by level")
+
+	if !strings.Contains(stdout.String(), `"request_id":"req-1"`) {
+		t.Errorf("stdout missing request_id: %s", stdout.String())
+	}
+	if len(exporter.records) != 1 {
+		t.Fatalf("exported %d records, want 1", len(exporter.records))
+	}
+	r := exporter.records[0]
+	if r.Body().AsString() != "order created" {
+		t.Errorf("body = %q", r.Body().AsString())
+	}
+	if r.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("trace id = %s, want %s", r.TraceID(), span.SpanContext().TraceID())
+	}
+	attrs := map[string]string{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value.String()
+		return true
+	})
+	for key, want := range map[string]string{
+		"request_id": "req-1",
+		"tenant":     "acme",
+		"trace_id":   span.SpanContext().TraceID().String(),
+		"password":   Redacted,
+	} {
+		if attrs[key] != want {
+			t.Errorf("attribute %s = %q, want %q", key, attrs[key], want)
+		}
+	}
+}
+
+func TestInitExportsLogs(t *testing.T) {
+	var mu sync.Mutex
+	paths := map[string]int{}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	shutdown, err := initForTest(t, Config{
+		ServiceName:  "svc",
+		OtlpEndpoint: collector.URL + "/otlp/v1/traces",
+		ExportLogs:   true,
+		SampleRatio:  1,
+	})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	slog.Info("hello")
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if paths["/otlp/v1/logs"] == 0 {
+		t.Errorf("no logs exported, collector saw %v", paths)
+	}
+	if paths["/otlp/v1/traces"] == 0 {
+		t.Errorf("no spans exported, collector saw %v", paths)
+	}
+}
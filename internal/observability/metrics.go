@@ -0,0 +1,163 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// durationBuckets are the latency histogram boundaries in seconds, as
+// recommended by the OTel semantic conventions.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// registry holds the Prometheus collectors of the MeterProvider created by
+// the last Init call. MetricsHandler serves it.
+var registry atomic.Pointer[prometheus.Registry]
+
+func init() {
+	registry.Store(prometheus.NewRegistry())
+}
+
+// newMeterProvider creates a MeterProvider exported through a fresh
+// Prometheus registry, and makes that registry the one MetricsHandler
+// serves.
+func newMeterProvider(res *resource.Resource) (*metricsdk.MeterProvider, error) {
+	reg := prometheus.NewRegistry()
+	exporter, err := otelprom.New(otelprom.WithRegisterer(reg))
+	if err != nil {
+		return nil, err
+	}
+	registry.Store(reg)
+	return metricsdk.NewMeterProvider(
+		metricsdk.WithReader(exporter),
+		metricsdk.WithResource(res),
+	), nil
+}
+
+// MetricsHandler serves the metrics recorded since Init in the Prometheus
+// text format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promhttp.HandlerFor(registry.Load(), promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// MetricsFiberHandler is MetricsHandler as a fiber handler, to be mounted
+// with app.Get("/metrics", MetricsFiberHandler()).
+func MetricsFiberHandler() fiber.Handler {
+	return adaptor.HTTPHandler(MetricsHandler())
+}
+
+// redMetrics records the rate, errors and duration of one kind of
+// operation. Instruments are created from the global MeterProvider and
+// re-created when a different provider is installed.
+type redMetrics struct {
+	prefix string
+	unit   string
+
+	mu       sync.Mutex
+	provider metric.MeterProvider
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+var (
+	httpServerMetrics = &redMetrics{prefix: "http.server", unit: "{request}"}
+	rpcServerMetrics  = &redMetrics{prefix: "rpc.server", unit: "{call}"}
+	rpcClientMetrics  = &redMetrics{prefix: "rpc.client", unit: "{call}"}
+	dbClientMetrics   = &redMetrics{prefix: "db.client", unit: "{operation}"}
+)
+
+func (m *redMetrics) instruments() (metric.Int64Counter, metric.Int64Counter, metric.Float64Histogram) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	provider := otel.GetMeterProvider()
+	if provider == m.provider {
+		return m.requests, m.errors, m.duration
+	}
+	meter := provider.Meter("gossiper")
+	// Instrument errors only report invalid names or options; the no-op
+	// instruments returned alongside them are still safe to use.
+	m.requests, _ = meter.Int64Counter(m.prefix+".requests",
+		metric.WithUnit(m.unit),
+		metric.WithDescription("Number of completed "+m.prefix+" operations."))
+	m.errors, _ = meter.Int64Counter(m.prefix+".errors",
+		metric.WithUnit(m.unit),
+		metric.WithDescription("Number of failed "+m.prefix+" operations."))
+	m.duration, _ = meter.Float64Histogram(m.prefix+".duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of "+m.prefix+" operations."),
+		metric.WithExplicitBucketBoundaries(durationBuckets...))
+	m.provider = provider
+	return m.requests, m.errors, m.duration
+}
+
+// record counts one operation that started at start, as an error when
+// failed is set.
+func (m *redMetrics) record(ctx context.Context, start time.Time, failed bool, attrs ...attribute.KeyValue) {
+	requests, errs, duration := m.instruments()
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	requests.Add(ctx, 1, set)
+	if failed {
+		errs.Add(ctx, 1, set)
+	}
+	duration.Record(ctx, time.Since(start).Seconds(), set)
+}
+
+// recordRPC records a gRPC call; every status other than OK is an error.
+func recordRPC(ctx context.Context, m *redMetrics, start time.Time, method string, err error) {
+	code := status.Code(err)
+	m.record(ctx, start, code != codes.OK,
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.method", method),
+		attribute.String("rpc.grpc.status_code", code.String()),
+	)
+}
+
+// recordHTTP records a request handled by FiberMiddleware. Handler errors
+// are reported with the status fiber's error handler will send; 5xx
+// responses count as errors.
+func recordHTTP(ctx context.Context, start time.Time, method, route string, httpStatus int, err error) {
+	if err != nil {
+		httpStatus = fiber.StatusInternalServerError
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			httpStatus = fe.Code
+		}
+	}
+	httpServerMetrics.record(ctx, start, httpStatus >= 500,
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.Int("http.response.status_code", httpStatus),
+	)
+}
+
+// RecordDBOperation records a database operation such as "query" or
+// "create" on table; a non-nil err counts as an error.
+func RecordDBOperation(ctx context.Context, system, operation, table string, start time.Time, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	dbClientMetrics.record(ctx, start, err != nil,
+		attribute.String("db.system", system),
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.collection.name", table),
+	)
+}
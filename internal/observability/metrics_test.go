@@ -0,0 +1,186 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func installMeterProvider(t *testing.T) {
+	t.Helper()
+	mp, err := newMeterProvider(resource.Empty())
+	if err != nil {
+		t.Fatalf("newMeterProvider: %v", err)
+	}
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() {
+		otel.SetMeterProvider(prev)
+		_ = mp.Shutdown(context.Background())
+	})
+}
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 {
+		t.Fatalf("scrape status = %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+// hasMetric reports whether body has a sample of name carrying all labels.
+func hasMetric(body, name string, labels ...string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, name+"{") {
+			continue
+		}
+		found := true
+		for _, l := range labels {
+			found = found && strings.Contains(line, l)
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func assertMetric(t *testing.T, body, name string, labels ...string) {
+	t.Helper()
+	if !hasMetric(body, name, labels...) {
+		t.Errorf("metrics output has no %s sample with %v:\n%s", name, labels, body)
+	}
+}
+
+func TestFiberMiddlewareRecordsREDMetrics(t *testing.T) {
+	installMeterProvider(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	app := fiber.New()
+	app.Use(FiberMiddleware(logger, noop.NewTracerProvider().Tracer("test")))
+	app.Get("/metrics", MetricsFiberHandler())
+	app.Get("/orders/:id", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/fail", func(c *fiber.Ctx) error { return errors.New("boom") })
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/fail"} {
+		res, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		res.Body.Close()
+	}
+
+	res, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	data, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	body := string(data)
+
+	assertMetric(t, body, "http_server_requests_total", `http_request_method="GET"`, `http_response_status_code="200"`, `http_route="/orders/:id"`)
+	assertMetric(t, body, "http_server_requests_total", `http_request_method="GET"`, `http_response_status_code="500"`, `http_route="/fail"`)
+	assertMetric(t, body, "http_server_errors_total", `http_request_method="GET"`, `http_response_status_code="500"`, `http_route="/fail"`)
+	assertMetric(t, body, "http_server_duration_seconds_bucket", `http_request_method="GET"`, `http_response_status_code="200"`, `http_route="/orders/:id"`)
+	if hasMetric(body, "http_server_errors_total", `http_response_status_code="200"`) {
+		t.Errorf("successful requests counted as errors:\n%s", body)
+	}
+}
+
+func TestGRPCInterceptorsRecordREDMetrics(t *testing.T) {
+	installMeterProvider(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tracer := noop.NewTracerProvider().Tracer("test")
+
+	server := GRPCServerInterceptor(logger, tracer)
+	info := &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/Get"}
+	_, _ = server(context.Background(), nil, info, func(context.Context, any) (any, error) { return "ok", nil })
+	_, _ = server(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+
+	client := GRPCClientInterceptor(logger, tracer)
+	_ = client(context.Background(), "/billing.Billing/Charge", nil, nil, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			time.Sleep(time.Millisecond)
+			return status.Error(codes.Unavailable, "down")
+		})
+
+	body := scrape(t)
+	assertMetric(t, body, "rpc_server_requests_total", `rpc_grpc_status_code="OK"`, `rpc_method="/orders.Orders/Get"`, `rpc_system="grpc"`)
+	assertMetric(t, body, "rpc_server_errors_total", `rpc_grpc_status_code="NotFound"`, `rpc_method="/orders.Orders/Get"`, `rpc_system="grpc"`)
+	assertMetric(t, body, "rpc_client_errors_total", `rpc_grpc_status_code="Unavailable"`, `rpc_method="/billing.Billing/Charge"`, `rpc_system="grpc"`)
+	assertMetric(t, body, "rpc_client_duration_seconds_count", `rpc_grpc_status_code="Unavailable"`, `rpc_method="/billing.Billing/Charge"`, `rpc_system="grpc"`)
+}
+
+// fakeClientStream answers every RecvMsg with the next of its results.
+type fakeClientStream struct {
+	grpc.ClientStream
+	results []error
+}
+
+func (s *fakeClientStream) RecvMsg(any) error {
+	err := s.results[0]
+	s.results = s.results[1:]
+	return err
+}
+
+func TestGRPCStreamClientInterceptorEndsSpan(t *testing.T) {
+	installMeterProvider(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name    string
+		desc    grpc.StreamDesc
+		results []error
+		reads   int
+	}{
+		// A client-streaming call reads its only response and stops.
+		{"client streaming", grpc.StreamDesc{ClientStreams: true}, []error{nil}, 1},
+		// A server-streaming call ends when RecvMsg reports io.EOF.
+		{"server streaming", grpc.StreamDesc{ServerStreams: true}, []error{nil, nil, io.EOF}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := tracetest.NewSpanRecorder()
+			tracer := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(spans)).Tracer("test")
+			method := "/upload.Upload/" + strings.ReplaceAll(tt.name, " ", "")
+
+			interceptor := GRPCStreamClientInterceptor(logger, tracer)
+			cs, err := interceptor(context.Background(), &tt.desc, nil, method,
+				func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+					return &fakeClientStream{results: tt.results}, nil
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.reads {
+				if len(spans.Ended()) != 0 {
+					t.Fatalf("span ended after %d of %d reads", i, tt.reads)
+				}
+				_ = cs.RecvMsg(nil)
+			}
+			if n := len(spans.Ended()); n != 1 {
+				t.Fatalf("%d spans ended, want 1", n)
+			}
+			assertMetric(t, scrape(t), "rpc_client_requests_total", `rpc_grpc_status_code="OK"`, `rpc_method="`+method+`"`)
+		})
+	}
+}
@@ -2,10 +2,14 @@ package observability
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
 	"log/slog"
-	"net/url"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,23 +17,56 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	logglobal "go.opentelemetry.io/otel/log/global"
 	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
 // Config controls how observability is initialised.
 type Config struct {
-	ServiceName  string
-	Environment  string
+	ServiceName string
+	Environment string
+	// OtlpEndpoint is "host:port" or a URL; an https:// URL enables TLS and
+	// a path overrides the default /v1/traces of OTLP/HTTP.
 	OtlpEndpoint string
-	SampleRatio  float64
-	LogLevel     slog.Level
+	// SampleRatio is the share of traces started by this service that are
+	// sampled; Sampling refines it per route and method.
+	SampleRatio float64
+	Sampling    SamplingConfig
+	LogLevel    slog.Level
+	// Exporter selects where spans go. Defaults to ExporterOTLP.
+	Exporter Exporter
+	// ExportFile is the file ExporterFile appends spans to.
+	ExportFile string
+	// OtlpProtocol selects OTLP over HTTP (default) or gRPC.
+	OtlpProtocol Protocol
+	// OtlpTLS enables TLS to the collector with this configuration, e.g.
+	// one built by tlsconfig.ClientConfig for mTLS.
+	OtlpTLS *tls.Config
+	// OtlpHeaders are sent with every export, e.g. collector credentials.
+	OtlpHeaders map[string]string
+	// OtlpCompression compresses export payloads.
+	OtlpCompression Compression
+	// ExportLogs also sends logs to the collector over OTLP, with the
+	// settings above, next to the JSON written to stdout. It only applies
+	// to ExporterOTLP.
+	ExportLogs bool
+	// RedactKeys lists attribute keys and header names masked in every log
+	// line, in addition to DefaultRedactedKeys.
+	RedactKeys []string
+	// RequestAttributes declares request attributes, such as "locale" or
+	// "cohort", set with WithRequestAttribute and carried as W3C baggage.
+	// Declared attributes are logged by LoggerFromContext and set on every
+	// span. AttributeTenant is always declared.
+	RequestAttributes []string
 }
 
 type ctxKey string
@@ -38,12 +75,30 @@ const (
 	ctxKeyRequestID ctxKey = "request-id"
 	ctxKeyTenant    ctxKey = "tenant"
 	ctxKeyUser      ctxKey = "user"
+	ctxKeyMDKeys    ctxKey = "metadata-keys"
 )
 
-// Init sets up the OTLP tracer provider and a JSON structured logger.
+const (
+	// DefaultTenantKey is the HTTP header and gRPC metadata key carrying the
+	// tenant of a request.
+	DefaultTenantKey = "namespace"
+	// DefaultUserKey is the gRPC metadata key carrying the user identified
+	// by an upstream service.
+	DefaultUserKey = "x-user-id"
+)
+
+// Init sets up the tracer provider with the exporter selected by cfg, a
+// MeterProvider whose RED metrics are served by MetricsHandler, and a JSON
+// structured logger, which with ExportLogs also feeds an OTLP
+// LoggerProvider. With ExporterNone nothing is exported but the logger
+// and tracer work as usual.
 // Returns logger, tracer, shutdown func, and any init error.
 // On error the caller should fall back to slog.Default() and a noop tracer.
 func Init(ctx context.Context, cfg Config) (*slog.Logger, trace.Tracer, func(context.Context) error, error) {
+	if err := setRequestAttributes(cfg.RequestAttributes); err != nil {
+		return nil, nil, nil, err
+	}
+
 	res, err := resource.Merge(
 		resource.Default(),
 		resource.NewSchemaless(
@@ -55,34 +110,66 @@ func Init(ctx context.Context, cfg Config) (*slog.Logger, trace.Tracer, func(con
 		return nil, nil, nil, err
 	}
 
-	exporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint(normalizeOTLPEndpoint(cfg.OtlpEndpoint)),
-		otlptracehttp.WithInsecure(),
-	)
+	exporter, closeExporter, err := newTraceExporter(ctx, cfg)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	tp := tracesdk.NewTracerProvider(
-		tracesdk.WithBatcher(exporter),
-		tracesdk.WithSampler(tracesdk.TraceIDRatioBased(cfg.SampleRatio)),
+	tpOpts := []tracesdk.TracerProviderOption{
+		tracesdk.WithSampler(newRuleSampler(cfg.SampleRatio, cfg.Sampling)),
 		tracesdk.WithResource(res),
-	)
+		tracesdk.WithSpanProcessor(requestAttributeProcessor{}),
+	}
+	if exporter != nil {
+		tpOpts = append(tpOpts, tracesdk.WithSpanProcessor(
+			newTailProcessor(tracesdk.NewBatchSpanProcessor(exporter), cfg.Sampling),
+		))
+	}
+	tp := tracesdk.NewTracerProvider(tpOpts...)
+
+	mp, err := newMeterProvider(res)
+	if err != nil {
+		_ = tp.Shutdown(ctx)
+		_ = closeExporter()
+		return nil, nil, nil, err
+	}
+
+	var handler slog.Handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level:     cfg.LogLevel,
+		AddSource: true,
+	})
+	var lp *sdklog.LoggerProvider
+	if cfg.ExportLogs && (cfg.Exporter == "" || cfg.Exporter == ExporterOTLP) {
+		lp, err = newLoggerProvider(ctx, cfg, res)
+		if err != nil {
+			_ = tp.Shutdown(ctx)
+			_ = mp.Shutdown(ctx)
+			_ = closeExporter()
+			return nil, nil, nil, err
+		}
+		handler = NewFanoutHandler(handler, newOTLPHandler(cfg.ServiceName, lp, cfg.LogLevel))
+		logglobal.SetLoggerProvider(lp)
+	}
 
 	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
 		propagation.Baggage{},
 	))
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level:     cfg.LogLevel,
-		AddSource: true,
-	})).With("service", cfg.ServiceName, "environment", cfg.Environment)
+	handler = NewRedactHandler(handler, slices.Concat(DefaultRedactedKeys, cfg.RedactKeys)...)
+	logger := slog.New(handler).With("service", cfg.ServiceName, "environment", cfg.Environment)
 
 	slog.SetDefault(logger)
 
-	shutdown := func(ctx context.Context) error { return tp.Shutdown(ctx) }
+	shutdown := func(ctx context.Context) error {
+		errs := []error{tp.Shutdown(ctx), mp.Shutdown(ctx)}
+		if lp != nil {
+			errs = append(errs, lp.Shutdown(ctx))
+		}
+		return errors.Join(append(errs, closeExporter())...)
+	}
 	return logger, tp.Tracer(cfg.ServiceName), shutdown, nil
 }
 
@@ -94,11 +181,34 @@ func RequestID(ctx context.Context) string {
 	return ""
 }
 
+// Tenant extracts the tenant set by WithRequestData from context, or
+// propagated by an upstream service in baggage.
+func Tenant(ctx context.Context) string {
+	if v, _ := ctx.Value(ctxKeyTenant).(string); v != "" {
+		return v
+	}
+	return RequestAttribute(ctx, AttributeTenant)
+}
+
+// WithTenant sets the tenant of the request, replacing one read by the
+// middleware, and the AttributeTenant request attribute.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	ctx = context.WithValue(ctx, ctxKeyTenant, tenant)
+	return WithRequestAttribute(ctx, AttributeTenant, tenant)
+}
+
+// User extracts the user set by WithRequestData from context.
+func User(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKeyUser).(string)
+	return v
+}
+
 // WithRequestData attaches request-scoped fields (requestID, tenant, user) to context.
+// The tenant is also set as the AttributeTenant request attribute.
 func WithRequestData(ctx context.Context, requestID, tenant, user string) context.Context {
 	ctx = context.WithValue(ctx, ctxKeyRequestID, requestID)
 	if tenant != "" {
-		ctx = context.WithValue(ctx, ctxKeyTenant, tenant)
+		ctx = WithTenant(ctx, tenant)
 	}
 	if user != "" {
 		ctx = context.WithValue(ctx, ctxKeyUser, user)
@@ -107,7 +217,7 @@ func WithRequestData(ctx context.Context, requestID, tenant, user string) contex
 }
 
 // LoggerFromContext enriches the base logger with request_id, trace_id, span_id, tenant, user
-// from context — giving every log line full tracing context.
+// and the declared request attributes from context — giving every log line full tracing context.
 func LoggerFromContext(ctx context.Context, base *slog.Logger) *slog.Logger {
 	if base == nil {
 		base = slog.Default()
@@ -119,19 +229,25 @@ func LoggerFromContext(ctx context.Context, base *slog.Logger) *slog.Logger {
 	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
 		attrs = append(attrs, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
 	}
-	if tenant, _ := ctx.Value(ctxKeyTenant).(string); tenant != "" {
+	if tenant := Tenant(ctx); tenant != "" {
 		attrs = append(attrs, "tenant", tenant)
 	}
-	if user, _ := ctx.Value(ctxKeyUser).(string); user != "" {
+	if user := User(ctx); user != "" {
 		attrs = append(attrs, "user", user)
 	}
+	for _, kv := range declaredAttributes(ctx) {
+		if kv.Key != AttributeTenant {
+			attrs = append(attrs, string(kv.Key), kv.Value.AsString())
+		}
+	}
 	if len(attrs) == 0 {
 		return base
 	}
 	return base.With(attrs...)
 }
 
-// WithOutgoingMetadata injects request-id and OTel trace headers into outgoing gRPC metadata.
+// WithOutgoingMetadata injects request-id, tenant, user and OTel trace headers into outgoing
+// gRPC metadata, under the keys configured on the middleware that handled the request.
 // Call this before every outgoing gRPC call so downstream services can correlate logs.
 func WithOutgoingMetadata(ctx context.Context) context.Context {
 	reqID := RequestID(ctx)
@@ -143,6 +259,13 @@ func WithOutgoingMetadata(ctx context.Context) context.Context {
 	md, _ := metadata.FromOutgoingContext(ctx)
 	md = md.Copy()
 	md.Set("x-request-id", reqID)
+	keys := metadataKeysFromContext(ctx)
+	if tenant := Tenant(ctx); tenant != "" {
+		md.Set(keys.tenant, tenant)
+	}
+	if user := User(ctx); user != "" {
+		md.Set(keys.user, user)
+	}
 
 	carrier := metadataCarrier(md)
 	otel.GetTextMapPropagator().Inject(ctx, carrier)
@@ -150,12 +273,115 @@ func WithOutgoingMetadata(ctx context.Context) context.Context {
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// MiddlewareOption configures FiberMiddleware and the gRPC server
+// interceptors.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	identity        IdentityExtractor
+	keys            metadataKeys
+	trustPropagated bool
+}
+
+// metadataKeys are the header and metadata keys carrying the tenant and
+// user of a request. The middleware stores them on the context so that
+// WithOutgoingMetadata propagates under the same keys.
+type metadataKeys struct {
+	tenant string
+	user   string
+}
+
+var defaultMetadataKeys = metadataKeys{tenant: DefaultTenantKey, user: DefaultUserKey}
+
+func withMetadataKeys(ctx context.Context, keys metadataKeys) context.Context {
+	return context.WithValue(ctx, ctxKeyMDKeys, keys)
+}
+
+func metadataKeysFromContext(ctx context.Context) metadataKeys {
+	if keys, ok := ctx.Value(ctxKeyMDKeys).(metadataKeys); ok {
+		return keys
+	}
+	return defaultMetadataKeys
+}
+
+func newMiddlewareConfig(opts []MiddlewareOption) middlewareConfig {
+	c := middlewareConfig{identity: DefaultIdentityExtractor, keys: defaultMetadataKeys}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithTenantKey sets the HTTP header and gRPC metadata key the tenant is
+// read from and propagated under. The default is DefaultTenantKey.
+func WithTenantKey(key string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.keys.tenant = strings.ToLower(key)
+	}
+}
+
+// WithUserKey sets the gRPC metadata key the user is propagated under to
+// downstream services, and read from with WithTrustedUserPropagation. The
+// default is DefaultUserKey.
+func WithUserKey(key string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.keys.user = strings.ToLower(key)
+	}
+}
+
+// WithTrustedUserPropagation makes the gRPC server interceptors take the
+// user from the user key when a caller sends one, so that logs along a
+// call chain name the end user rather than the calling service. Any
+// client can set that key: only use it on servers reachable solely by
+// trusted services.
+func WithTrustedUserPropagation() MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.trustPropagated = true
+	}
+}
+
+// incomingIdentity returns the tenant and user of a gRPC call. The user is
+// derived from the metadata and the peer's TLS state; the one propagated
+// by an upstream service is only taken with WithTrustedUserPropagation.
+func (c middlewareConfig) incomingIdentity(ctx context.Context, md metadata.MD) (tenant, user string) {
+	tenant = firstFromMD(md, c.keys.tenant)
+	if c.trustPropagated {
+		user = firstFromMD(md, c.keys.user)
+	}
+	if user == "" {
+		user = c.identity(grpcCredentials(ctx, md))
+	}
+	return tenant, user
+}
+
+// grpcCredentials exposes the metadata and peer TLS state of a call to an
+// IdentityExtractor.
+func grpcCredentials(ctx context.Context, md metadata.MD) Credentials {
+	creds := Credentials{Header: func(key string) string { return firstFromMD(md, key) }}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			creds.TLS = &info.State
+		}
+	}
+	return creds
+}
+
+// WithIdentityExtractor sets how the user logged with each request is
+// derived from its credentials. The default is DefaultIdentityExtractor.
+func WithIdentityExtractor(e IdentityExtractor) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.identity = e
+	}
+}
+
 // FiberMiddleware instruments all incoming HTTP requests:
 // - extracts or generates request_id
 // - propagates OTel trace context from headers
+// - identifies the caller with the configured IdentityExtractor
 // - creates a server span for the request
 // - logs completion (or failure) with full tracing fields
-func FiberMiddleware(logger *slog.Logger, tracer trace.Tracer) func(c *fiber.Ctx) error {
+func FiberMiddleware(logger *slog.Logger, tracer trace.Tracer, opts ...MiddlewareOption) func(c *fiber.Ctx) error {
+	cfg := newMiddlewareConfig(opts)
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 		ctx := c.UserContext()
@@ -181,11 +407,18 @@ func FiberMiddleware(logger *slog.Logger, tracer trace.Tracer) func(c *fiber.Ctx
 			reqID = uuid.NewString()
 		}
 
-		ctx = WithRequestData(ctx, reqID, c.Get("Namespace"), c.Get("Authorization"))
+		user := cfg.identity(Credentials{Header: carrier.Get, TLS: c.Context().TLSConnectionState()})
+		ctx = WithRequestData(ctx, reqID, c.Get(cfg.keys.tenant), user)
+		ctx = withMetadataKeys(ctx, cfg.keys)
 		ctx = WithOutgoingMetadata(ctx)
 
 		ctx, span := tracer.Start(ctx, c.Method()+" "+c.Route().Path,
 			trace.WithSpanKind(trace.SpanKindServer),
+			// Set at start so the sampler can apply route rules.
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+			),
 		)
 		defer span.End()
 
@@ -196,6 +429,7 @@ func FiberMiddleware(logger *slog.Logger, tracer trace.Tracer) func(c *fiber.Ctx
 
 		log := LoggerFromContext(ctx, logger)
 		httpStatus := c.Response().StatusCode()
+		recordHTTP(ctx, start, c.Method(), c.Route().Path, httpStatus, err)
 
 		if err != nil {
 			span.RecordError(err)
@@ -227,25 +461,64 @@ func FiberMiddleware(logger *slog.Logger, tracer trace.Tracer) func(c *fiber.Ctx
 	}
 }
 
+// incomingRequestID returns the request ID already on ctx (set by an
+// earlier request-id interceptor), the one sent by the caller, or a new one.
+func incomingRequestID(ctx context.Context, md metadata.MD) string {
+	if id := RequestID(ctx); id != "" {
+		return id
+	}
+	if id := firstFromMD(md, "x-request-id", "x-correlation-id"); id != "" {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// GRPCRequestIDServerInterceptor puts the caller's x-request-id (or a new
+// one) on the context and echoes it in the response header, so handlers and
+// later interceptors share one ID even without the tracing interceptor.
+func GRPCRequestIDServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		reqID := incomingRequestID(ctx, md)
+		_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", reqID))
+		return handler(WithRequestData(ctx, reqID, "", ""), req)
+	}
+}
+
+// GRPCRequestIDStreamServerInterceptor is the streaming counterpart of
+// GRPCRequestIDServerInterceptor.
+func GRPCRequestIDStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		md, _ := metadata.FromIncomingContext(ctx)
+		reqID := incomingRequestID(ctx, md)
+		_ = ss.SetHeader(metadata.Pairs("x-request-id", reqID))
+		return handler(srv, &serverStream{ServerStream: ss, ctx: WithRequestData(ctx, reqID, "", "")})
+	}
+}
+
 // GRPCServerInterceptor adds tracing, structured logging, and request_id propagation
-// to every incoming gRPC unary call. Use as grpc.UnaryInterceptor on the server.
-func GRPCServerInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.UnaryServerInterceptor {
+// to every incoming gRPC unary call, with the tenant and user read from the metadata
+// keys set by opts. Use as grpc.UnaryInterceptor on the server.
+func GRPCServerInterceptor(logger *slog.Logger, tracer trace.Tracer, opts ...MiddlewareOption) grpc.UnaryServerInterceptor {
+	cfg := newMiddlewareConfig(opts)
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
 		md, _ := metadata.FromIncomingContext(ctx)
 		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
 
-		reqID := firstFromMD(md, "x-request-id", "x-correlation-id")
-		if reqID == "" {
-			reqID = uuid.NewString()
-		}
+		reqID := incomingRequestID(ctx, md)
+		tenant, user := cfg.incomingIdentity(ctx, md)
 
-		ctx = WithRequestData(ctx, reqID, "", "")
+		ctx = WithRequestData(ctx, reqID, tenant, user)
+		ctx = withMetadataKeys(ctx, cfg.keys)
 		ctx = WithOutgoingMetadata(ctx)
 
+		start := time.Now()
 		ctx, span := tracer.Start(ctx, info.FullMethod, trace.WithSpanKind(trace.SpanKindServer))
 		defer span.End()
 
 		resp, err := handler(ctx, req)
+		recordRPC(ctx, rpcServerMetrics, start, info.FullMethod, err)
 
 		log := LoggerFromContext(ctx, logger)
 		grpcCode := status.Code(err)
@@ -274,10 +547,12 @@ func GRPCServerInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.UnaryS
 func GRPCClientInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		ctx = WithOutgoingMetadata(ctx)
+		start := time.Now()
 		ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
 		defer span.End()
 
 		err := invoker(ctx, method, req, reply, cc, opts...)
+		recordRPC(ctx, rpcClientMetrics, start, method, err)
 		if err != nil {
 			span.RecordError(err)
 			span.SetStatus(codes.Error, err.Error())
@@ -295,6 +570,119 @@ func GRPCClientInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.UnaryC
 	}
 }
 
+// GRPCStreamServerInterceptor is the streaming counterpart of
+// GRPCServerInterceptor. Use as grpc.StreamInterceptor on the server.
+func GRPCStreamServerInterceptor(logger *slog.Logger, tracer trace.Tracer, opts ...MiddlewareOption) grpc.StreamServerInterceptor {
+	cfg := newMiddlewareConfig(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+		reqID := incomingRequestID(ctx, md)
+		tenant, user := cfg.incomingIdentity(ctx, md)
+
+		ctx = WithRequestData(ctx, reqID, tenant, user)
+		ctx = withMetadataKeys(ctx, cfg.keys)
+		ctx = WithOutgoingMetadata(ctx)
+
+		start := time.Now()
+		ctx, span := tracer.Start(ctx, info.FullMethod, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		recordRPC(ctx, rpcServerMetrics, start, info.FullMethod, err)
+
+		log := LoggerFromContext(ctx, logger)
+		grpcCode := status.Code(err)
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			log.Error("grpc stream failed",
+				slog.String("method", info.FullMethod),
+				slog.String("grpc_code", grpcCode.String()),
+				slog.String("error", err.Error()),
+			)
+			return err
+		}
+
+		log.Info("grpc stream completed",
+			slog.String("method", info.FullMethod),
+			slog.String("grpc_code", grpcCode.String()),
+		)
+		return nil
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// GRPCStreamClientInterceptor is the streaming counterpart of
+// GRPCClientInterceptor. The client span ends when the stream does. Use as
+// grpc.WithStreamInterceptor on the client.
+func GRPCStreamClientInterceptor(logger *slog.Logger, tracer trace.Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = WithOutgoingMetadata(ctx)
+		start := time.Now()
+		ctx, span := tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient))
+
+		finish := func(err error) {
+			recordRPC(ctx, rpcClientMetrics, start, method, err)
+			log := LoggerFromContext(ctx, logger)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				log.Error("grpc client stream failed",
+					slog.String("method", method),
+					slog.String("error", err.Error()),
+				)
+			} else {
+				log.Info("grpc client stream completed",
+					slog.String("method", method),
+				)
+			}
+			span.End()
+		}
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finish(err)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, finish: finish, single: !desc.ServerStreams}, nil
+	}
+}
+
+// clientStream calls finish once, when RecvMsg reports the end of the
+// stream (io.EOF counts as success), or after the only response of a
+// client-streaming call, which is never read again.
+type clientStream struct {
+	grpc.ClientStream
+	once   sync.Once
+	finish func(error)
+	single bool
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || s.single {
+		s.once.Do(func() {
+			if err == nil || errors.Is(err, io.EOF) {
+				s.finish(nil)
+				return
+			}
+			s.finish(err)
+		})
+	}
+	return err
+}
+
 // mapCarrier adapts a plain string map to an OTel propagation.TextMapCarrier.
 type mapCarrier map[string]string
 
@@ -343,20 +731,3 @@ func firstFromMD(md metadata.MD, keys ...string) string {
 	}
 	return ""
 }
-
-// normalizeOTLPEndpoint strips scheme prefixes from the endpoint URL
-// because the OTLP HTTP exporter expects host:port, not a full URL.
-func normalizeOTLPEndpoint(endpoint string) string {
-	endpoint = strings.TrimSpace(endpoint)
-	if endpoint == "" {
-		return endpoint
-	}
-	if strings.Contains(endpoint, "://") {
-		if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
-			return parsed.Host
-		}
-	}
-	endpoint = strings.TrimPrefix(endpoint, "http://")
-	endpoint = strings.TrimPrefix(endpoint, "https://")
-	return strings.TrimSuffix(endpoint, "/")
-}
@@ -0,0 +1,142 @@
+package observability
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Redacted replaces the values of sensitive log attributes.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedKeys are the attribute keys and header names masked by
+// Init's logger in addition to Config.RedactKeys.
+var DefaultRedactedKeys = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"password",
+	"secret",
+	"token",
+	"access_token",
+	"refresh_token",
+}
+
+// redactHandler masks sensitive attributes before passing records on.
+type redactHandler struct {
+	next slog.Handler
+	keys map[string]struct{}
+}
+
+// NewRedactHandler wraps next so that attributes whose key is one of keys,
+// compared case-insensitively and inside groups too, are logged as
+// Redacted. Header maps (http.Header, gRPC metadata) have the values of
+// those keys masked, and string values carrying Bearer or Basic
+// credentials are masked whatever their key.
+func NewRedactHandler(next slog.Handler, keys ...string) slog.Handler {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = struct{}{}
+	}
+	return &redactHandler{next: next, keys: set}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted), keys: h.keys}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), keys: h.keys}
+}
+
+func (h *redactHandler) sensitive(key string) bool {
+	_, ok := h.keys[strings.ToLower(key)]
+	return ok
+}
+
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	if h.sensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		if hasCredentials(v.String()) {
+			return slog.String(a.Key, Redacted)
+		}
+	case slog.KindAny:
+		switch m := v.Any().(type) {
+		case http.Header:
+			return slog.Any(a.Key, http.Header(h.redactMultiMap(m)))
+		case metadata.MD:
+			return slog.Any(a.Key, metadata.MD(h.redactMultiMap(m)))
+		case map[string][]string:
+			return slog.Any(a.Key, h.redactMultiMap(m))
+		case map[string]string:
+			out := make(map[string]string, len(m))
+			for k, val := range m {
+				if h.sensitive(k) || hasCredentials(val) {
+					val = Redacted
+				}
+				out[k] = val
+			}
+			return slog.Any(a.Key, out)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func (h *redactHandler) redactMultiMap(m map[string][]string) map[string][]string {
+	out := make(map[string][]string, len(m))
+	for k, values := range m {
+		if h.sensitive(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		masked := make([]string, len(values))
+		for i, val := range values {
+			if hasCredentials(val) {
+				val = Redacted
+			}
+			masked[i] = val
+		}
+		out[k] = masked
+	}
+	return out
+}
+
+// hasCredentials reports whether s looks like an Authorization header
+// value.
+func hasCredentials(s string) bool {
+	scheme, rest, ok := strings.Cut(s, " ")
+	return ok && rest != "" && (strings.EqualFold(scheme, "Bearer") || strings.EqualFold(scheme, "Basic"))
+}
@@ -0,0 +1,46 @@
+package observability
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRedactHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		log    func(l *slog.Logger)
+		secret string
+		keep   string
+	}{
+		{"sensitive key", func(l *slog.Logger) { l.Info("x", "Authorization", "abc123", "route", "/a") }, "abc123", `"route":"/a"`},
+		{"bearer value under any key", func(l *slog.Logger) { l.Info("x", "header", "Bearer abc123") }, "abc123", `"header":"[REDACTED]"`},
+		{"key in group", func(l *slog.Logger) { l.Info("x", slog.Group("req", "password", "abc123", "id", 7)) }, "abc123", `"id":7`},
+		{"logger attrs", func(l *slog.Logger) { l.With("token", "abc123").Info("x", "ok", true) }, "abc123", `"ok":true`},
+		{"custom key", func(l *slog.Logger) { l.Info("x", "ssn", "abc123") }, "abc123", `"ssn":"[REDACTED]"`},
+		{"http header", func(l *slog.Logger) {
+			l.Info("x", "headers", http.Header{"X-Api-Key": {"abc123"}, "Accept": {"json"}})
+		}, "abc123", `"Accept":["json"]`},
+		{"grpc metadata", func(l *slog.Logger) {
+			l.Info("x", "md", metadata.Pairs("authorization", "Bearer abc123", "x-request-id", "r1"))
+		}, "abc123", `"x-request-id":["r1"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			keys := append([]string{"ssn"}, DefaultRedactedKeys...)
+			tt.log(slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), keys...)))
+			out := buf.String()
+			if strings.Contains(out, tt.secret) {
+				t.Errorf("secret logged: %s", out)
+			}
+			if !strings.Contains(out, tt.keep) {
+				t.Errorf("log has no %s: %s", tt.keep, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package observability
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultIgnoredRoutes are never traced: health probes would otherwise
+// dominate every trace backend.
+var DefaultIgnoredRoutes = []string{
+	"/healthz",
+	"/readyz",
+	"/grpc.health.v1.Health/*",
+}
+
+const (
+	// maxPendingSpans bounds how many spans of one unsampled trace are
+	// held back in case the trace turns out to fail or be slow.
+	maxPendingSpans = 256
+	// maxPendingTraces bounds how many unsampled traces are held back at
+	// once. Traces started beyond it are not held: only their own failed
+	// spans are kept.
+	maxPendingTraces = 4096
+	// maxPendingAge is how long an unsampled trace is held back waiting
+	// for its local root to end, so roots that never end, such as
+	// abandoned streams, do not pile up.
+	maxPendingAge = 5 * time.Minute
+)
+
+// SamplingConfig refines the head sampling ratio of Config.SampleRatio.
+// Spans whose parent is sampled are always sampled, and spans whose parent
+// is not are only kept when the trace fails or is slow.
+//
+// Traces kept after the fact are partial: services this one called were
+// told the trace was not sampled and dropped their spans, so only the
+// spans recorded here are exported. Keeping them also has a cost: every
+// request not picked by the ratio is fully recorded in memory until its
+// local root span ends. Set DropErrors with no SlowThreshold to drop those
+// requests outright instead.
+//
+// Patterns are exact paths or methods, or prefixes ending in "*"; the
+// longest matching pattern wins.
+type SamplingConfig struct {
+	// Routes sets the ratio of HTTP requests by path, e.g. "/orders/*".
+	Routes map[string]float64
+	// Methods sets the ratio of gRPC calls by full method or service, e.g.
+	// "/billing.Billing/*".
+	Methods map[string]float64
+	// Ignore lists HTTP paths and gRPC methods that are never traced, in
+	// addition to DefaultIgnoredRoutes.
+	Ignore []string
+	// SlowThreshold keeps traces not picked by the ratio whose local root
+	// span lasts longer than this. Zero disables it.
+	SlowThreshold time.Duration
+	// DropErrors stops keeping traces not picked by the ratio that recorded
+	// an error. By default they are kept.
+	DropErrors bool
+}
+
+// patternMatch returns the value of the longest pattern matching name.
+func patternMatch[V any](patterns map[string]V, name string) (V, bool) {
+	if v, ok := patterns[name]; ok {
+		return v, true
+	}
+	var best V
+	bestLen, found := -1, false
+	for pattern, v := range patterns {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(name, prefix) && len(prefix) > bestLen {
+			best, bestLen, found = v, len(prefix), true
+		}
+	}
+	return best, found
+}
+
+// ruleSampler implements the sampling policy of SamplingConfig. Spans not
+// picked are still recorded (RecordOnly) so tailProcessor can keep the
+// traces that fail or are slow.
+type ruleSampler struct {
+	ratio   float64
+	routes  map[string]float64
+	methods map[string]float64
+	ignore  map[string]struct{}
+	// recordUnsampled is false when nothing would be kept after the fact,
+	// so unsampled spans can be dropped outright.
+	recordUnsampled bool
+}
+
+func newRuleSampler(ratio float64, cfg SamplingConfig) *ruleSampler {
+	ignore := make(map[string]struct{})
+	for _, p := range DefaultIgnoredRoutes {
+		ignore[p] = struct{}{}
+	}
+	for _, p := range cfg.Ignore {
+		ignore[p] = struct{}{}
+	}
+	return &ruleSampler{
+		ratio:           ratio,
+		routes:          cfg.Routes,
+		methods:         cfg.Methods,
+		ignore:          ignore,
+		recordUnsampled: !cfg.DropErrors || cfg.SlowThreshold > 0,
+	}
+}
+
+// target returns the HTTP path of a span, or its name (the gRPC method).
+// otelgrpc names spans "pkg.Service/Method"; a leading slash is added so
+// patterns are written alike for every span.
+func target(p tracesdk.SamplingParameters) (name string, isRoute bool) {
+	for _, a := range p.Attributes {
+		if a.Key == "url.path" {
+			return a.Value.AsString(), true
+		}
+	}
+	if strings.Contains(p.Name, "/") && !strings.HasPrefix(p.Name, "/") && !strings.Contains(p.Name, " ") {
+		return "/" + p.Name, false
+	}
+	return p.Name, false
+}
+
+func (s *ruleSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	psc := trace.SpanContextFromContext(p.ParentContext)
+	result := tracesdk.SamplingResult{Tracestate: psc.TraceState()}
+
+	name, isRoute := target(p)
+	if _, ok := patternMatch(s.ignore, name); ok {
+		result.Decision = tracesdk.Drop
+		return result
+	}
+
+	if psc.IsValid() {
+		switch {
+		case psc.IsSampled():
+			result.Decision = tracesdk.RecordAndSample
+		case !psc.IsRemote() && !trace.SpanFromContext(p.ParentContext).IsRecording():
+			// The local parent was dropped, e.g. an ignored health check.
+			result.Decision = tracesdk.Drop
+		default:
+			result.Decision = s.unsampled()
+		}
+		return result
+	}
+
+	ratio := s.ratio
+	rules := s.methods
+	if isRoute {
+		rules = s.routes
+	}
+	if r, ok := patternMatch(rules, name); ok {
+		ratio = r
+	}
+	if tracesdk.TraceIDRatioBased(ratio).ShouldSample(p).Decision == tracesdk.RecordAndSample {
+		result.Decision = tracesdk.RecordAndSample
+	} else {
+		result.Decision = s.unsampled()
+	}
+	return result
+}
+
+func (s *ruleSampler) unsampled() tracesdk.SamplingDecision {
+	if s.recordUnsampled {
+		return tracesdk.RecordOnly
+	}
+	return tracesdk.Drop
+}
+
+func (s *ruleSampler) Description() string {
+	return "GossiperRuleSampler"
+}
+
+// tailProcessor passes sampled spans to next. Recorded but unsampled spans
+// are held per trace until the local root span ends, and passed on, marked
+// sampled, when the trace recorded an error or the root was slow.
+//
+// At most maxPendingTraces traces are held, each for at most maxPendingAge.
+type tailProcessor struct {
+	next       tracesdk.SpanProcessor
+	slow       time.Duration
+	keepErrors bool
+	maxTraces  int
+	maxAge     time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	pending   map[trace.TraceID]*pendingTrace
+	lastSweep time.Time
+}
+
+type pendingTrace struct {
+	root    trace.SpanID
+	started time.Time
+	spans   []tracesdk.ReadOnlySpan
+	failed  bool
+}
+
+func newTailProcessor(next tracesdk.SpanProcessor, cfg SamplingConfig) *tailProcessor {
+	return &tailProcessor{
+		next:       next,
+		slow:       cfg.SlowThreshold,
+		keepErrors: !cfg.DropErrors,
+		maxTraces:  maxPendingTraces,
+		maxAge:     maxPendingAge,
+		now:        time.Now,
+		pending:    make(map[trace.TraceID]*pendingTrace),
+	}
+}
+
+func (p *tailProcessor) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	sc := s.SpanContext()
+	if !sc.IsSampled() {
+		psc := trace.SpanContextFromContext(parent)
+		if !psc.IsValid() || psc.IsRemote() {
+			p.hold(sc.TraceID(), sc.SpanID())
+		}
+	}
+	p.next.OnStart(parent, s)
+}
+
+// hold starts holding back the spans of an unsampled trace whose local
+// root is root, unless maxTraces traces are already held.
+func (p *tailProcessor) hold(id trace.TraceID, root trace.SpanID) {
+	now := p.now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	full := len(p.pending) >= p.maxTraces
+	// Sweep once per maxAge, or at most once a second while full.
+	if since := now.Sub(p.lastSweep); since >= p.maxAge || (full && since >= time.Second) {
+		p.lastSweep = now
+		for id, t := range p.pending {
+			if now.Sub(t.started) >= p.maxAge {
+				delete(p.pending, id)
+			}
+		}
+	}
+	if len(p.pending) >= p.maxTraces {
+		return
+	}
+	p.pending[id] = &pendingTrace{root: root, started: now}
+}
+
+func (p *tailProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	sc := s.SpanContext()
+	if sc.IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+	failed := p.keepErrors && s.Status().Code == codes.Error
+
+	p.mu.Lock()
+	t, ok := p.pending[sc.TraceID()]
+	if !ok {
+		p.mu.Unlock()
+		if failed {
+			p.next.OnEnd(sampledSpan{s})
+		}
+		return
+	}
+	if len(t.spans) < maxPendingSpans {
+		t.spans = append(t.spans, s)
+	}
+	t.failed = t.failed || failed
+	if sc.SpanID() != t.root {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.pending, sc.TraceID())
+	p.mu.Unlock()
+
+	slow := p.slow > 0 && s.EndTime().Sub(s.StartTime()) > p.slow
+	if t.failed || slow {
+		for _, span := range t.spans {
+			p.next.OnEnd(sampledSpan{span})
+		}
+	}
+}
+
+func (p *tailProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *tailProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// sampledSpan marks a kept span as sampled so exporters accept it.
+type sampledSpan struct {
+	tracesdk.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
@@ -0,0 +1,168 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPatternMatch(t *testing.T) {
+	patterns := map[string]float64{
+		"/orders/*":        0.5,
+		"/orders/export/*": 0,
+		"/orders/export":   1,
+	}
+	tests := []struct {
+		name  string
+		want  float64
+		found bool
+	}{
+		{"/orders/1", 0.5, true},
+		{"/orders/export/csv", 0, true},
+		{"/orders/export", 1, true},
+		{"/users", 0, false},
+	}
+	for _, tt := range tests {
+		got, found := patternMatch(patterns, tt.name)
+		if got != tt.want || found != tt.found {
+			t.Errorf("patternMatch(%q) = %v, %v; want %v, %v", tt.name, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestRuleSamplerDecisions(t *testing.T) {
+	s := newRuleSampler(0, SamplingConfig{
+		Routes:  map[string]float64{"/orders/*": 1},
+		Methods: map[string]float64{"/billing.Billing/*": 1},
+		Ignore:  []string{"/internal/*"},
+	})
+	remote := func(sampled bool) context.Context {
+		var flags trace.TraceFlags
+		if sampled {
+			flags = trace.FlagsSampled
+		}
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}, TraceFlags: flags, Remote: true,
+		}))
+	}
+	route := func(path string) []attribute.KeyValue {
+		return []attribute.KeyValue{attribute.String("url.path", path)}
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		span  string
+		attrs []attribute.KeyValue
+		want  tracesdk.SamplingDecision
+	}{
+		{"route rule", context.Background(), "GET /", route("/orders/7"), tracesdk.RecordAndSample},
+		{"default ratio", context.Background(), "GET /", route("/users"), tracesdk.RecordOnly},
+		{"method rule", context.Background(), "billing.Billing/Charge", nil, tracesdk.RecordAndSample},
+		{"sampled parent wins over ratio", remote(true), "GET /", route("/users"), tracesdk.RecordAndSample},
+		{"unsampled parent wins over rule", remote(false), "GET /", route("/orders/7"), tracesdk.RecordOnly},
+		{"health route", remote(true), "GET /", route("/healthz"), tracesdk.Drop},
+		{"grpc health", context.Background(), "grpc.health.v1.Health/Check", nil, tracesdk.Drop},
+		{"custom ignore", context.Background(), "GET /", route("/internal/debug"), tracesdk.Drop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.ShouldSample(tracesdk.SamplingParameters{
+				ParentContext: tt.ctx, TraceID: trace.TraceID{2}, Name: tt.span, Attributes: tt.attrs,
+			}).Decision
+			if got != tt.want {
+				t.Errorf("decision = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailProcessorKeepsFailedAndSlowTraces(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := SamplingConfig{SlowThreshold: 20 * time.Millisecond}
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithSampler(newRuleSampler(0, cfg)),
+		tracesdk.WithSpanProcessor(newTailProcessor(tracesdk.NewSimpleSpanProcessor(exporter), cfg)),
+	)
+	tracer := tp.Tracer("test")
+
+	run := func(path string, childErr error, sleep time.Duration) {
+		ctx, root := tracer.Start(context.Background(), "GET "+path,
+			trace.WithAttributes(attribute.String("url.path", path)))
+		_, child := tracer.Start(ctx, "query")
+		if childErr != nil {
+			child.RecordError(childErr)
+			child.SetStatus(codes.Error, childErr.Error())
+		}
+		child.End()
+		time.Sleep(sleep)
+		root.End()
+	}
+
+	run("/ok", nil, 0)
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Fatalf("unsampled successful trace exported %d spans", n)
+	}
+
+	run("/failing", errors.New("boom"), 0)
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("failed trace exported %d spans, want 2", len(spans))
+	}
+	for _, s := range spans {
+		if !s.SpanContext.IsSampled() {
+			t.Errorf("exported span %q is not marked sampled", s.Name)
+		}
+	}
+	exporter.Reset()
+
+	run("/slow", nil, 30*time.Millisecond)
+	if n := len(exporter.GetSpans()); n != 2 {
+		t.Errorf("slow trace exported %d spans, want 2", n)
+	}
+	exporter.Reset()
+
+	run("/healthz", errors.New("boom"), 30*time.Millisecond)
+	if n := len(exporter.GetSpans()); n != 0 {
+		t.Errorf("health check exported %d spans", n)
+	}
+}
+
+func TestTailProcessorBoundsPendingTraces(t *testing.T) {
+	cfg := SamplingConfig{SlowThreshold: time.Second}
+	tail := newTailProcessor(tracesdk.NewSimpleSpanProcessor(tracetest.NewInMemoryExporter()), cfg)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tail.now = func() time.Time { return now }
+	tail.lastSweep = now
+	tail.maxTraces = 2
+	tracer := tracesdk.NewTracerProvider(
+		tracesdk.WithSampler(newRuleSampler(0, cfg)),
+		tracesdk.WithSpanProcessor(tail),
+	).Tracer("test")
+	pending := func() int {
+		tail.mu.Lock()
+		defer tail.mu.Unlock()
+		return len(tail.pending)
+	}
+
+	// Roots that never end, like abandoned streams.
+	for range 3 {
+		tracer.Start(context.Background(), "/svc.Svc/Watch")
+	}
+	if n := pending(); n != 2 {
+		t.Fatalf("%d traces held, want the bound of 2", n)
+	}
+
+	now = now.Add(tail.maxAge)
+	tracer.Start(context.Background(), "/svc.Svc/Watch")
+	if n := pending(); n != 1 {
+		t.Errorf("%d traces held after they expired, want only the new one", n)
+	}
+}
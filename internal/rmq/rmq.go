@@ -0,0 +1,117 @@
+package rmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+)
+
+// Channel is the subset of *amqp.Channel used by the RabbitMQ server and
+// transport. It exists so both can be tested against a fake broker.
+type Channel interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+	Qos(prefetchCount, prefetchSize int, global bool) error
+	Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error)
+	Cancel(consumer string, noWait bool) error
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	// Confirm puts the channel in publisher confirm mode.
+	Confirm(noWait bool) error
+	// PublishConfirmed publishes msg on a channel in confirm mode and
+	// returns the broker's pending confirmation of it.
+	PublishConfirmed(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) (Confirmation, error)
+	// IsClosed reports whether the channel was closed, by Close or by the
+	// broker after a channel error, while the connection may still be
+	// open.
+	IsClosed() bool
+	Close() error
+}
+
+// Confirmation is the broker's answer to one publish in confirm mode.
+type Confirmation interface {
+	// WaitContext returns true once the broker acks the publish and
+	// false if it nacks it or the channel closes first.
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+// Connection is the subset of *amqp.Connection used by this package.
+type Connection interface {
+	Channel() (Channel, error)
+	IsClosed() bool
+	Close() error
+}
+
+// Dialer opens a broker connection for an AMQP URL.
+type Dialer func(url string) (Connection, error)
+
+// Dial connects to a real RabbitMQ broker.
+func Dial(url string) (Connection, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	return amqpConnection{conn}, nil
+}
+
+type amqpConnection struct{ *amqp.Connection }
+
+func (c amqpConnection) Channel() (Channel, error) {
+	ch, err := c.Connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+	return amqpChannel{ch}, nil
+}
+
+type amqpChannel struct{ *amqp.Channel }
+
+func (c amqpChannel) PublishConfirmed(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) (Confirmation, error) {
+	confirmation, err := c.PublishWithDeferredConfirmWithContext(ctx, exchange, key, mandatory, immediate, msg)
+	if err != nil {
+		return nil, err
+	}
+	if confirmation == nil {
+		return nil, errors.New("rmq: channel is not in confirm mode")
+	}
+	return confirmation, nil
+}
+
+// HeaderRequestID carries the request ID between services, mirroring the
+// x-request-id gRPC metadata key.
+const HeaderRequestID = "x-request-id"
+
+// HeadersCarrier adapts AMQP message headers to an OTel
+// propagation.TextMapCarrier so trace context travels with messages.
+type HeadersCarrier amqp.Table
+
+func (h HeadersCarrier) Get(key string) string {
+	if v, ok := h[strings.ToLower(key)].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (h HeadersCarrier) Set(key, value string) { h[strings.ToLower(key)] = value }
+
+func (h HeadersCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Inject writes the trace context from ctx into headers.
+func Inject(ctx context.Context, headers amqp.Table) {
+	otel.GetTextMapPropagator().Inject(ctx, HeadersCarrier(headers))
+}
+
+// Extract returns ctx with the trace context found in headers.
+func Extract(ctx context.Context, headers amqp.Table) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, HeadersCarrier(headers))
+}
@@ -0,0 +1,343 @@
+// Package rmqtest provides an in-memory fake RabbitMQ broker for testing
+// code built on the rmq Connection and Channel interfaces.
+package rmqtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/rmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Binding records a QueueBind call.
+type Binding struct {
+	Queue, Key, Exchange string
+}
+
+// Published records a PublishWithContext call.
+type Published struct {
+	Exchange, Key string
+	Msg           amqp.Publishing
+}
+
+// Outcome records how a consumer settled a delivery.
+type Outcome struct {
+	Tag     uint64
+	Ack     bool
+	Requeue bool
+}
+
+// Broker is a fake broker. Its Dial method satisfies rmq.Dialer. Queues
+// are not routed: tests push deliveries to consumers with Deliver and
+// observe settlements on Outcomes.
+type Broker struct {
+	// Outcomes receives one entry per Ack or Nack.
+	Outcomes chan Outcome
+
+	mu        sync.Mutex
+	exchanges map[string]string
+	queues    map[string]amqp.Table
+	bindings  []Binding
+	published []Published
+	prefetch  int
+	consumers map[string]*consumer
+	nextTag   uint64
+	conns     []*conn
+	channels  []*channel
+	nack      bool
+}
+
+type consumer struct {
+	queue      string
+	deliveries chan amqp.Delivery
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		Outcomes:  make(chan Outcome, 128),
+		exchanges: make(map[string]string),
+		queues:    make(map[string]amqp.Table),
+		consumers: make(map[string]*consumer),
+	}
+}
+
+// Dial opens a fake connection.
+func (b *Broker) Dial(string) (rmq.Connection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := &conn{broker: b}
+	b.conns = append(b.conns, c)
+	return c, nil
+}
+
+// ExchangeKind returns the declared kind of exchange name, or "" if it was
+// never declared.
+func (b *Broker) ExchangeKind(name string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exchanges[name]
+}
+
+// QueueArgs returns the arguments queue was declared with and whether it
+// was declared at all.
+func (b *Broker) QueueArgs(queue string) (amqp.Table, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	args, ok := b.queues[queue]
+	return args, ok
+}
+
+// Bindings returns every binding declared so far.
+func (b *Broker) Bindings() []Binding {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Binding(nil), b.bindings...)
+}
+
+// Published returns every message published so far.
+func (b *Broker) Published() []Published {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Published(nil), b.published...)
+}
+
+// Prefetch returns the last prefetch count set with Qos.
+func (b *Broker) Prefetch() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.prefetch
+}
+
+// Consuming reports whether a consumer is attached to queue.
+func (b *Broker) Consuming(queue string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.consumers {
+		if c.queue == queue {
+			return true
+		}
+	}
+	return false
+}
+
+// Deliver hands msg to a consumer of queue and returns its delivery tag.
+func (b *Broker) Deliver(queue string, msg amqp.Publishing) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, c := range b.consumers {
+		if c.queue != queue {
+			continue
+		}
+		b.nextTag++
+		c.deliveries <- amqp.Delivery{
+			Acknowledger: acknowledger{b},
+			Headers:      msg.Headers,
+			ContentType:  msg.ContentType,
+			Body:         msg.Body,
+			DeliveryTag:  b.nextTag,
+			RoutingKey:   queue,
+		}
+		return b.nextTag, nil
+	}
+	return 0, errors.New("rmqtest: no consumer on " + queue)
+}
+
+// CloseChannels simulates the broker closing every channel after a channel
+// error, such as a publish to a missing exchange, while the connections
+// stay open.
+func (b *Broker) CloseChannels() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.channels {
+		ch.closed = true
+	}
+}
+
+// NackPublishes makes the broker nack confirmed publishes instead of
+// acking them.
+func (b *Broker) NackPublishes(nack bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nack = nack
+}
+
+// CloseConnections simulates the broker dropping every connection.
+func (b *Broker) CloseConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for tag, c := range b.consumers {
+		close(c.deliveries)
+		delete(b.consumers, tag)
+	}
+	for _, c := range b.conns {
+		c.closed = true
+	}
+	for _, ch := range b.channels {
+		ch.closed = true
+	}
+}
+
+type acknowledger struct{ b *Broker }
+
+func (a acknowledger) Ack(tag uint64, _ bool) error {
+	a.b.Outcomes <- Outcome{Tag: tag, Ack: true}
+	return nil
+}
+
+func (a acknowledger) Nack(tag uint64, _ bool, requeue bool) error {
+	a.b.Outcomes <- Outcome{Tag: tag, Requeue: requeue}
+	return nil
+}
+
+func (a acknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+type conn struct {
+	broker *Broker
+	closed bool
+}
+
+func (c *conn) Channel() (rmq.Channel, error) {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	ch := &channel{broker: c.broker}
+	c.broker.channels = append(c.broker.channels, ch)
+	return ch, nil
+}
+
+func (c *conn) IsClosed() bool {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	return c.closed
+}
+
+func (c *conn) Close() error {
+	c.broker.mu.Lock()
+	defer c.broker.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+type channel struct {
+	broker  *Broker
+	closed  bool
+	confirm bool
+}
+
+func (ch *channel) ExchangeDeclare(name, kind string, _, _, _, _ bool, _ amqp.Table) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	ch.broker.exchanges[name] = kind
+	return nil
+}
+
+func (ch *channel) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	if args == nil {
+		args = amqp.Table{}
+	}
+	ch.broker.queues[name] = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (ch *channel) QueueBind(name, key, exchange string, _ bool, _ amqp.Table) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	ch.broker.bindings = append(ch.broker.bindings, Binding{Queue: name, Key: key, Exchange: exchange})
+	return nil
+}
+
+func (ch *channel) Qos(prefetchCount, _ int, _ bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	ch.broker.prefetch = prefetchCount
+	return nil
+}
+
+func (ch *channel) Consume(queue, tag string, _, _, _, _ bool, _ amqp.Table) (<-chan amqp.Delivery, error) {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	if _, ok := ch.broker.queues[queue]; !ok {
+		return nil, errors.New("rmqtest: queue not declared: " + queue)
+	}
+	c := &consumer{queue: queue, deliveries: make(chan amqp.Delivery, 128)}
+	ch.broker.consumers[tag] = c
+	return c.deliveries, nil
+}
+
+func (ch *channel) Cancel(tag string, _ bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	if c, ok := ch.broker.consumers[tag]; ok {
+		close(c.deliveries)
+		delete(ch.broker.consumers, tag)
+	}
+	return nil
+}
+
+func (ch *channel) PublishWithContext(ctx context.Context, exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	_, err := ch.publish(ctx, exchange, key, msg)
+	return err
+}
+
+func (ch *channel) Confirm(bool) error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	if ch.closed {
+		return amqp.ErrClosed
+	}
+	ch.confirm = true
+	return nil
+}
+
+func (ch *channel) PublishConfirmed(ctx context.Context, exchange, key string, _, _ bool, msg amqp.Publishing) (rmq.Confirmation, error) {
+	ack, err := ch.publish(ctx, exchange, key, msg)
+	if err != nil {
+		return nil, err
+	}
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	if !ch.confirm {
+		return nil, errors.New("rmqtest: channel is not in confirm mode")
+	}
+	return confirmation(ack), nil
+}
+
+// publish records msg and reports whether the broker acks it.
+func (ch *channel) publish(ctx context.Context, exchange, key string, msg amqp.Publishing) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	if ch.closed {
+		return false, amqp.ErrClosed
+	}
+	ch.broker.published = append(ch.broker.published, Published{Exchange: exchange, Key: key, Msg: msg})
+	return !ch.broker.nack, nil
+}
+
+func (ch *channel) IsClosed() bool {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	return ch.closed
+}
+
+func (ch *channel) Close() error {
+	ch.broker.mu.Lock()
+	defer ch.broker.mu.Unlock()
+	ch.closed = true
+	return nil
+}
+
+// confirmation is an immediate broker answer.
+type confirmation bool
+
+func (c confirmation) WaitContext(context.Context) (bool, error) { return bool(c), nil }
@@ -0,0 +1,150 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
+)
+
+// Component is a non-server dependency (database, telemetry exporter,
+// tenant sync, ...) whose lifecycle ServerManager.Run manages. Components
+// are started before any server and stopped only after every server has
+// drained.
+type Component interface {
+	OnStart(ctx context.Context) error
+	OnStop(ctx context.Context) error
+}
+
+// Hooks adapts a pair of plain functions to Component. Either may be nil.
+type Hooks struct {
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+func (h Hooks) OnStart(ctx context.Context) error {
+	if h.Start == nil {
+		return nil
+	}
+	return h.Start(ctx)
+}
+
+func (h Hooks) OnStop(ctx context.Context) error {
+	if h.Stop == nil {
+		return nil
+	}
+	return h.Stop(ctx)
+}
+
+type namedComponent struct {
+	name      string
+	component Component
+	dependsOn []string
+}
+
+// AddComponent registers a component under a unique name. dependsOn lists
+// the names of components that must be started before it (and therefore
+// stopped after it). Names are resolved when Run is called, so components
+// may be registered in any order. Components that implement
+// health.Checker are added to the readiness checks under the same name.
+func (sm *ServerManager) AddComponent(name string, component Component, dependsOn ...string) {
+	if hc, ok := component.(health.Checker); ok {
+		sm.health.Register(name, hc.HealthCheck)
+	}
+	sm.components = append(sm.components, namedComponent{
+		name:      name,
+		component: component,
+		dependsOn: dependsOn,
+	})
+}
+
+// componentOrder returns components in dependency order, keeping
+// registration order among components that do not depend on each other.
+func (sm *ServerManager) componentOrder() ([]namedComponent, error) {
+	byName := make(map[string]int, len(sm.components))
+	for i, c := range sm.components {
+		if _, dup := byName[c.name]; dup {
+			return nil, fmt.Errorf("component %q registered more than once", c.name)
+		}
+		byName[c.name] = i
+	}
+	for _, c := range sm.components {
+		for _, dep := range c.dependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("component %q depends on unknown component %q", c.name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(sm.components))
+	ordered := make([]namedComponent, 0, len(sm.components))
+	var path []string
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		c := sm.components[i]
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("component dependency cycle: %s -> %s", strings.Join(path, " -> "), c.name)
+		}
+		state[i] = visiting
+		path = append(path, c.name)
+		for _, dep := range c.dependsOn {
+			if err := visit(byName[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		ordered = append(ordered, c)
+		return nil
+	}
+
+	for i := range sm.components {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// startComponents starts components in order. If one fails, the ones
+// already started are stopped in reverse order before returning.
+func startComponents(ctx context.Context, components []namedComponent) error {
+	for i, c := range components {
+		if err := c.component.OnStart(ctx); err != nil {
+			startErr := fmt.Errorf("start component %q: %w", c.name, err)
+			if stopErr := stopComponents(context.WithoutCancel(ctx), components[:i]); stopErr != nil {
+				return fmt.Errorf("%w (rollback: %v)", startErr, stopErr)
+			}
+			return startErr
+		}
+		slog.Info("component started", "component", c.name)
+	}
+	return nil
+}
+
+// stopComponents stops components in reverse order, continuing past
+// failures and returning them joined.
+func stopComponents(ctx context.Context, components []namedComponent) error {
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if err := c.component.OnStop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop component %q: %w", c.name, err))
+			continue
+		}
+		slog.Info("component stopped", "component", c.name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/tenant"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Stage is a named position in the server interceptor chain built by
+// NewServer. Stages run in the order they are declared, for unary and
+// stream calls alike.
+type Stage int
+
+const (
+	// StageRecovery turns handler panics into codes.Internal. Built in.
+	StageRecovery Stage = iota
+	// StageRequestID puts the caller's x-request-id, or a new one, on the
+	// context. Built in.
+	StageRequestID
+	// StageObservability creates spans and logs each call. Built in when
+	// WithObservability is set.
+	StageObservability
+	// StageAuth authenticates the caller.
+	StageAuth
+	// StageTenant resolves the tenant of the call.
+	StageTenant
+	// StageValidation rejects invalid requests. Built in when WithValidation
+	// is set.
+	StageValidation
+	// StageRateLimit throttles callers.
+	StageRateLimit
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageRecovery:
+		return "recovery"
+	case StageRequestID:
+		return "request-id"
+	case StageObservability:
+		return "observability"
+	case StageAuth:
+		return "auth"
+	case StageTenant:
+		return "tenant"
+	case StageValidation:
+		return "validation"
+	case StageRateLimit:
+		return "rate-limit"
+	default:
+		return "unknown"
+	}
+}
+
+type unaryEntry struct {
+	stage       Stage
+	interceptor grpc.UnaryServerInterceptor
+}
+
+type streamEntry struct {
+	stage       Stage
+	interceptor grpc.StreamServerInterceptor
+}
+
+type chainConfig struct {
+	serverOpts []grpc.ServerOption
+	unary      []unaryEntry
+	stream     []streamEntry
+}
+
+// Option configures a server built by NewServer.
+type Option func(*chainConfig)
+
+// WithServerOptions passes opts, such as TLSServerOption, to grpc.NewServer.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(c *chainConfig) {
+		c.serverOpts = append(c.serverOpts, opts...)
+	}
+}
+
+// WithUnaryInterceptor adds i at stage, after the interceptors already
+// registered there.
+func WithUnaryInterceptor(stage Stage, i grpc.UnaryServerInterceptor) Option {
+	return func(c *chainConfig) {
+		c.unary = append(c.unary, unaryEntry{stage, i})
+	}
+}
+
+// WithStreamInterceptor adds i at stage, after the interceptors already
+// registered there.
+func WithStreamInterceptor(stage Stage, i grpc.StreamServerInterceptor) Option {
+	return func(c *chainConfig) {
+		c.stream = append(c.stream, streamEntry{stage, i})
+	}
+}
+
+// WithObservability adds the tracing and logging interceptors at
+// StageObservability, configured by opts.
+func WithObservability(logger *slog.Logger, tracer trace.Tracer, opts ...observability.MiddlewareOption) Option {
+	return func(c *chainConfig) {
+		WithUnaryInterceptor(StageObservability, observability.GRPCServerInterceptor(logger, tracer, opts...))(c)
+		WithStreamInterceptor(StageObservability, observability.GRPCStreamServerInterceptor(logger, tracer, opts...))(c)
+	}
+}
+
+// WithTenant adds the tenant resolution interceptors at StageTenant: each
+// call reaches the tenant's schema through tenant.Transaction.
+func WithTenant(registry tenant.Registry, db tenant.Database, opts ...tenant.MiddlewareOption) Option {
+	return func(c *chainConfig) {
+		WithUnaryInterceptor(StageTenant, tenant.GRPCServerInterceptor(registry, db, opts...))(c)
+		WithStreamInterceptor(StageTenant, tenant.GRPCStreamServerInterceptor(registry, db, opts...))(c)
+	}
+}
+
+// WithValidation adds interceptors at StageValidation that reject requests
+// whose Validate() method (as generated by protoc-gen-validate) fails with
+// codes.InvalidArgument.
+func WithValidation() Option {
+	return func(c *chainConfig) {
+		WithUnaryInterceptor(StageValidation, ValidationUnaryServerInterceptor())(c)
+		WithStreamInterceptor(StageValidation, ValidationStreamServerInterceptor())(c)
+	}
+}
+
+// NewServer creates a grpc.Server with the otelgrpc stats handler and a
+// canonical interceptor chain: recovery, request ID, tracing and logging,
+// auth, tenant resolution, validation and rate limiting. Custom
+// interceptors are placed at a Stage with WithUnaryInterceptor and
+// WithStreamInterceptor.
+func NewServer(opts ...Option) *grpc.Server {
+	c := &chainConfig{}
+	WithUnaryInterceptor(StageRecovery, RecoveryUnaryServerInterceptor())(c)
+	WithStreamInterceptor(StageRecovery, RecoveryStreamServerInterceptor())(c)
+	WithUnaryInterceptor(StageRequestID, observability.GRPCRequestIDServerInterceptor())(c)
+	WithStreamInterceptor(StageRequestID, observability.GRPCRequestIDStreamServerInterceptor())(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	sort.SliceStable(c.unary, func(i, j int) bool { return c.unary[i].stage < c.unary[j].stage })
+	sort.SliceStable(c.stream, func(i, j int) bool { return c.stream[i].stage < c.stream[j].stage })
+	unary := make([]grpc.UnaryServerInterceptor, 0, len(c.unary))
+	for _, e := range c.unary {
+		unary = append(unary, e.interceptor)
+	}
+	stream := make([]grpc.StreamServerInterceptor, 0, len(c.stream))
+	for _, e := range c.stream {
+		stream = append(stream, e.interceptor)
+	}
+
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	return grpc.NewServer(append(serverOpts, c.serverOpts...)...)
+}
+
+// validator is implemented by messages generated with protoc-gen-validate.
+type validator interface {
+	Validate() error
+}
+
+// ValidationUnaryServerInterceptor rejects requests whose Validate method
+// fails with codes.InvalidArgument.
+func ValidationUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if v, ok := req.(validator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ValidationStreamServerInterceptor validates every message received on
+// the stream like ValidationUnaryServerInterceptor.
+func ValidationStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, validatingStream{ss})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if v, ok := m.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return nil
+}
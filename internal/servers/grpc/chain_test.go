@@ -0,0 +1,141 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type chainHealthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (chainHealthServer) Check(_ context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.GetService() == "panic" {
+		panic("boom")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (chainHealthServer) Watch(_ *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+// recorder records the order stages run in and whether a request ID was
+// already on the context.
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) add(ctx context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if observability.RequestID(ctx) == "" {
+		name += "(no request id)"
+	}
+	r.order = append(r.order, name)
+}
+
+func (r *recorder) unary(name string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		r.add(ctx, name)
+		return handler(ctx, req)
+	}
+}
+
+func (r *recorder) stream(name string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		r.add(ss.Context(), name)
+		return handler(srv, ss)
+	}
+}
+
+func dialChain(t *testing.T, opts ...Option) healthpb.HealthClient {
+	t.Helper()
+	server := NewServer(opts...)
+	healthpb.RegisterHealthServer(server, chainHealthServer{})
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestNewServer_RunsStagesInCanonicalOrder(t *testing.T) {
+	rec := &recorder{}
+	// Registered out of order on purpose.
+	client := dialChain(t,
+		WithUnaryInterceptor(StageRateLimit, rec.unary("rate-limit")),
+		WithUnaryInterceptor(StageTenant, rec.unary("tenant")),
+		WithUnaryInterceptor(StageAuth, rec.unary("auth")),
+		WithUnaryInterceptor(StageAuth, rec.unary("auth-2")),
+		WithStreamInterceptor(StageRateLimit, rec.stream("stream-rate-limit")),
+		WithStreamInterceptor(StageAuth, rec.stream("stream-auth")),
+	)
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"auth", "auth-2", "tenant", "rate-limit", "stream-auth", "stream-rate-limit"}
+	if !slices.Equal(rec.order, want) {
+		t.Errorf("stages ran as %v, want %v", rec.order, want)
+	}
+}
+
+func TestNewServer_RecoversPanics(t *testing.T) {
+	client := dialChain(t)
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected codes.Internal, got %v", err)
+	}
+}
+
+type validatedRequest struct{ valid bool }
+
+func (r validatedRequest) Validate() error {
+	if !r.valid {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func TestValidationUnaryServerInterceptor(t *testing.T) {
+	interceptor := ValidationUnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+	handler := func(ctx context.Context, req any) (any, error) { return "ok", nil }
+
+	if _, err := interceptor(context.Background(), validatedRequest{valid: false}, info, handler); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("invalid request: expected codes.InvalidArgument, got %v", err)
+	}
+	if resp, err := interceptor(context.Background(), validatedRequest{valid: true}, info, handler); err != nil || resp != "ok" {
+		t.Errorf("valid request: got %v, %v", resp, err)
+	}
+}
@@ -2,13 +2,16 @@ package grpc
 
 import (
 	"context"
+	"crypto/tls"
 	"log/slog"
 	"net"
 	"runtime/debug"
 
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/inprocess"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 )
 
@@ -33,6 +36,25 @@ func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
 	}
 }
 
+// RecoveryStreamServerInterceptor is the streaming counterpart of
+// RecoveryUnaryServerInterceptor: a panic in a stream handler ends that
+// stream with codes.Internal instead of crashing the process.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("recovered from panic in gRPC stream handler",
+					slog.String("method", info.FullMethod),
+					slog.Any("panic", r),
+					slog.String("stack", string(debug.Stack())),
+				)
+				err = status.Errorf(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
 type Server struct {
 	Port      string
 	Server    *grpc.Server
@@ -48,6 +70,12 @@ func NewDefaultServer(opts ...grpc.ServerOption) *grpc.Server {
 	return grpc.NewServer(append(defaults, opts...)...)
 }
 
+// TLSServerOption makes the server terminate TLS with cfg. Build cfg with
+// tlsconfig.ServerConfig to get mTLS and certificate hot reload.
+func TLSServerOption(cfg *tls.Config) grpc.ServerOption {
+	return grpc.Creds(credentials.NewTLS(cfg))
+}
+
 func New(port string, server *grpc.Server, initRoute func(server *grpc.Server)) *Server {
 	if initRoute != nil {
 		initRoute(server)
@@ -59,11 +87,19 @@ func New(port string, server *grpc.Server, initRoute func(server *grpc.Server))
 	}
 }
 
+// Start serves on the TCP port and, in memory, under the port for InProcess
+// transports, so modules running in one binary can skip the network
+// without changing their client code.
 func (g *Server) Start() error {
 	listener, err := net.Listen("tcp", ":"+g.Port)
 	if err != nil {
 		return err
 	}
+	if stop, err := inprocess.Serve(g.Port, g.Server); err != nil {
+		slog.Warn("gRPC server not reachable in-process", "port", g.Port, "error", err)
+	} else {
+		defer stop()
+	}
 	slog.Info("gRPC server running", "port", g.Port)
 	return g.Server.Serve(listener)
 }
@@ -73,3 +109,22 @@ func (g *Server) Stop() error {
 	slog.Info("gRPC server stopped")
 	return nil
 }
+
+// Shutdown drains in-flight RPCs like Stop, but force-closes any remaining
+// connections once ctx expires so a stuck stream cannot block shutdown.
+func (g *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.Server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("gRPC server stopped")
+		return nil
+	case <-ctx.Done():
+		g.Server.Stop()
+		slog.Warn("gRPC server force-stopped after drain deadline")
+		return ctx.Err()
+	}
+}
@@ -61,3 +61,17 @@ func TestRecoveryUnaryServerInterceptor_PassesThroughHandlerError(t *testing.T)
 		t.Errorf("expected handler error to pass through unchanged, got %v", err)
 	}
 }
+
+func TestRecoveryStreamServerInterceptor_RecoversPanic(t *testing.T) {
+	interceptor := RecoveryStreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Export"}
+
+	handler := func(srv any, stream grpc.ServerStream) error {
+		panic("boom")
+	}
+
+	err := interceptor(nil, nil, info, handler)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected codes.Internal, got %v", err)
+	}
+}
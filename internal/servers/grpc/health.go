@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// LivenessService is the grpc.health.v1 service name that reports liveness
+// only. The empty service name reports overall readiness, and every
+// registered check is also addressable by its own name.
+const LivenessService = "liveness"
+
+// healthWatchInterval is how often Watch re-evaluates a status. Tests
+// shorten it.
+var healthWatchInterval = 5 * time.Second
+
+// healthServer serves grpc.health.v1 from a health.Registry.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	registry *health.Registry
+}
+
+// RegisterHealth exposes registry as the standard grpc.health.v1 service
+// on the server, unless one has already been registered.
+func (g *Server) RegisterHealth(registry *health.Registry) {
+	if _, exists := g.Server.GetServiceInfo()[healthpb.Health_ServiceDesc.ServiceName]; exists {
+		return
+	}
+	healthpb.RegisterHealthServer(g.Server, &healthServer{registry: registry})
+}
+
+func (h *healthServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	switch service {
+	case LivenessService:
+		return healthpb.HealthCheckResponse_SERVING, nil
+	case "":
+		if h.registry.Ready(ctx).Ready {
+			return healthpb.HealthCheckResponse_SERVING, nil
+		}
+		return healthpb.HealthCheckResponse_NOT_SERVING, nil
+	}
+	found, err := h.registry.CheckOne(ctx, service)
+	if !found {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, status.Errorf(codes.NotFound, "unknown service %q", service)
+	}
+	if err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING, nil
+	}
+	return healthpb.HealthCheckResponse_SERVING, nil
+}
+
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := h.status(ctx, req.GetService())
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+func (h *healthServer) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	names := append([]string{"", LivenessService}, h.registry.Names()...)
+	resp := &healthpb.HealthListResponse{Statuses: make(map[string]*healthpb.HealthCheckResponse, len(names))}
+	for _, name := range names {
+		st, _ := h.status(ctx, name)
+		resp.Statuses[name] = &healthpb.HealthCheckResponse{Status: st}
+	}
+	return resp, nil
+}
+
+// Watch sends the current status immediately and then again whenever it
+// changes. Unknown services are reported as SERVICE_UNKNOWN rather than
+// failing the stream, as the protocol requires. Once the registry starts
+// draining, Watch sends NOT_SERVING and ends the stream, so open watches
+// do not hold up the server's graceful stop.
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, _ := h.status(ctx, req.GetService())
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-h.registry.DrainingC():
+			return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING})
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serveHealth serves registry as grpc.health.v1 in memory and returns a
+// client for it.
+func serveHealth(t *testing.T, registry *health.Registry) healthpb.HealthClient {
+	client, _ := serveHealthServer(t, registry)
+	return client
+}
+
+func serveHealthServer(t *testing.T, registry *health.Registry) (healthpb.HealthClient, *Server) {
+	t.Helper()
+	s := New("0", grpc.NewServer(), nil)
+	s.RegisterHealth(registry)
+	s.RegisterHealth(registry) // a second registration is ignored
+
+	lis := bufconn.Listen(1 << 16)
+	go s.Server.Serve(lis)
+	t.Cleanup(s.Server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///health",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn), s
+}
+
+func checkStatus(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Check(%q): %v", service, err)
+	}
+	return resp.GetStatus()
+}
+
+func TestHealth_Check(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.Register("postgres", func(context.Context) error { return nil })
+	registry.Register("billing", func(context.Context) error { return errors.New("unavailable") })
+	client := serveHealth(t, registry)
+
+	tests := []struct {
+		service string
+		want    healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{LivenessService, healthpb.HealthCheckResponse_SERVING},
+		{"", healthpb.HealthCheckResponse_NOT_SERVING},
+		{"postgres", healthpb.HealthCheckResponse_SERVING},
+		{"billing", healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, tt := range tests {
+		if got := checkStatus(t, client, tt.service); got != tt.want {
+			t.Errorf("Check(%q) = %v, want %v", tt.service, got, tt.want)
+		}
+	}
+
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Check(missing) = %v, want NotFound", err)
+	}
+
+	list, err := client.List(context.Background(), &healthpb.HealthListRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if got := list.GetStatuses()[tt.service].GetStatus(); got != tt.want {
+			t.Errorf("List[%q] = %v, want %v", tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestHealth_DrainingIsNotServing(t *testing.T) {
+	registry := health.NewRegistry()
+	client := serveHealth(t, registry)
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("readiness = %v before draining, want SERVING", got)
+	}
+
+	registry.SetDraining(true)
+	if got := checkStatus(t, client, ""); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("readiness = %v while draining, want NOT_SERVING", got)
+	}
+	if got := checkStatus(t, client, LivenessService); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("liveness = %v while draining, want SERVING", got)
+	}
+}
+
+func TestHealth_WatchSendsChanges(t *testing.T) {
+	prev := healthWatchInterval
+	healthWatchInterval = 10 * time.Millisecond
+	t.Cleanup(func() { healthWatchInterval = prev })
+
+	registry := health.NewRegistry()
+	client := serveHealth(t, registry)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func() healthpb.HealthCheckResponse_ServingStatus {
+		t.Helper()
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		return resp.GetStatus()
+	}
+	if got := recv(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("first status = %v, want SERVING", got)
+	}
+	registry.SetDraining(true)
+	if got := recv(); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after draining = %v, want NOT_SERVING", got)
+	}
+	if _, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv after draining = %v, want the stream to end", err)
+	}
+
+	unknown, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := unknown.Recv()
+	if err != nil {
+		t.Fatalf("Watch of an unknown service failed the stream: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		t.Errorf("unknown service status = %v, want SERVICE_UNKNOWN", resp.GetStatus())
+	}
+}
+
+func TestHealth_DrainingLetsGracefulStopFinish(t *testing.T) {
+	registry := health.NewRegistry()
+	client, s := serveHealthServer(t, registry)
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{Service: LivenessService})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	registry.SetDraining(true)
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("GracefulStop waited for an open Watch stream")
+	}
+}
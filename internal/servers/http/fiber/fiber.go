@@ -1,9 +1,11 @@
 package fiber
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
 )
 
 type Server struct {
@@ -32,3 +34,26 @@ func (r *Server) Stop() error {
 	slog.Info("REST server stopping")
 	return r.App.Shutdown()
 }
+
+// Shutdown stops the REST server, waiting for in-flight requests until ctx
+// expires.
+func (r *Server) Shutdown(ctx context.Context) error {
+	slog.Info("REST server stopping")
+	return r.App.ShutdownWithContext(ctx)
+}
+
+// RegisterHealth mounts GET /healthz (liveness) and GET /readyz (readiness)
+// backed by registry. /readyz answers 503 while any check fails or the
+// server manager is draining.
+func (r *Server) RegisterHealth(registry *health.Registry) {
+	r.App.Get("/healthz", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok"})
+	})
+	r.App.Get("/readyz", func(c *fiber.Ctx) error {
+		report := registry.Ready(c.UserContext())
+		if !report.Ready {
+			c.Status(fiber.StatusServiceUnavailable)
+		}
+		return c.JSON(report)
+	})
+}
@@ -0,0 +1,59 @@
+package fiber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
+)
+
+func get(t *testing.T, app *fiber.App, path string) (int, map[string]any) {
+	t.Helper()
+	res, err := app.Test(httptest.NewRequest("GET", path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var body map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("%s: decode body: %v", path, err)
+	}
+	return res.StatusCode, body
+}
+
+func TestRegisterHealth(t *testing.T) {
+	var dbErr error
+	registry := health.NewRegistry()
+	registry.Register("postgres", func(context.Context) error { return dbErr })
+	s := New("0", fiber.New(), nil)
+	s.RegisterHealth(registry)
+
+	if code, body := get(t, s.App, "/healthz"); code != fiber.StatusOK || body["status"] != "ok" {
+		t.Errorf("/healthz = %d %v, want 200 status ok", code, body)
+	}
+	if code, body := get(t, s.App, "/readyz"); code != fiber.StatusOK || body["ready"] != true {
+		t.Errorf("/readyz = %d %v, want 200 ready", code, body)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, body := get(t, s.App, "/readyz")
+	if code != fiber.StatusServiceUnavailable || body["ready"] != false {
+		t.Errorf("/readyz with a failing check = %d %v, want 503 not ready", code, body)
+	}
+	if checks, _ := body["checks"].(map[string]any); checks["postgres"] != "connection refused" {
+		t.Errorf("/readyz checks = %v, want the postgres error", body["checks"])
+	}
+	if code, _ := get(t, s.App, "/healthz"); code != fiber.StatusOK {
+		t.Errorf("/healthz with a failing check = %d, want 200", code)
+	}
+
+	dbErr = nil
+	registry.SetDraining(true)
+	if code, body := get(t, s.App, "/readyz"); code != fiber.StatusServiceUnavailable || body["draining"] != true {
+		t.Errorf("/readyz while draining = %d %v, want 503 draining", code, body)
+	}
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+)
+
+// Path is where the server exposes metrics.
+const Path = "/metrics"
+
+// Server serves GET /metrics on its own port, for services without a REST
+// server or that keep metrics off their public port.
+type Server struct {
+	Port   string
+	server *http.Server
+}
+
+func New(port string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+Path, observability.MetricsHandler())
+	return &Server{
+		Port: port,
+		server: &http.Server{
+			Addr:              ":" + port,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *Server) Start() error {
+	slog.Info("Metrics server running", "port", s.Port)
+	if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Stop() error {
+	slog.Info("Metrics server stopping")
+	return s.server.Close()
+}
+
+// Shutdown stops the metrics server, waiting for in-flight scrapes until
+// ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	slog.Info("Metrics server stopping")
+	return s.server.Shutdown(ctx)
+}
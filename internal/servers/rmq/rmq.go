@@ -0,0 +1,328 @@
+package rmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/rmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultPrefetch is the number of unacknowledged deliveries (and
+// concurrent handlers) per consumer when Topology.Prefetch is not set.
+const DefaultPrefetch = 10
+
+// Topology describes the exchange, queue and dead-letter routing the
+// server declares before it starts consuming. Everything is declared
+// durable and idempotently, so several replicas can share it.
+type Topology struct {
+	// Exchange to bind the queue to. Empty means the default exchange,
+	// where messages are routed by queue name.
+	Exchange string
+	// ExchangeType is "topic" unless set ("direct", "fanout", "headers").
+	ExchangeType string
+	// Queue to consume from. Required.
+	Queue string
+	// RoutingKeys bind Queue to Exchange. Defaults to the queue name.
+	RoutingKeys []string
+	// DeadLetterExchange, when set, receives messages whose handler
+	// failed. A "<Queue>.dlq" queue bound to it is declared as well.
+	DeadLetterExchange string
+	// Prefetch limits unacknowledged deliveries and sets handler
+	// concurrency. Defaults to DefaultPrefetch.
+	Prefetch int
+}
+
+// Handler processes one delivery. Returning nil acks it; returning an
+// error rejects it to the dead-letter exchange (or drops it when none is
+// configured), unless the error is wrapped with Requeue.
+type Handler func(ctx context.Context, d amqp.Delivery) error
+
+type requeueError struct{ err error }
+
+func (e requeueError) Error() string { return e.err.Error() }
+func (e requeueError) Unwrap() error { return e.err }
+
+// Requeue marks a handler error as transient: the delivery is returned to
+// the queue instead of being dead-lettered.
+func Requeue(err error) error {
+	return requeueError{err: err}
+}
+
+type Server struct {
+	URL      string
+	Topology Topology
+	Handler  Handler
+
+	dial     rmq.Dialer
+	tag      string
+	stopping atomic.Bool
+	workers  sync.WaitGroup
+
+	mu        sync.Mutex
+	conn      rmq.Connection
+	ch        rmq.Channel
+	consuming bool // workers are counted and the consumer is registered
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithDialer replaces the real AMQP dialer, e.g. with a fake broker in
+// tests.
+func WithDialer(d rmq.Dialer) Option {
+	return func(s *Server) {
+		s.dial = d
+	}
+}
+
+func New(url string, topology Topology, handler Handler, opts ...Option) *Server {
+	s := &Server{
+		URL:      url,
+		Topology: topology,
+		Handler:  handler,
+		dial:     rmq.Dial,
+		tag:      "gossiper-" + uuid.NewString(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Server) Start() error {
+	if s.Topology.Queue == "" {
+		return errors.New("rmq: Topology.Queue is required")
+	}
+	conn, err := s.dial(s.URL)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("rmq: open channel: %w", err)
+	}
+	s.mu.Lock()
+	s.conn, s.ch = conn, ch
+	s.mu.Unlock()
+
+	if err := s.declare(ch); err != nil {
+		s.closeConn()
+		return err
+	}
+	prefetch := s.Topology.Prefetch
+	if prefetch <= 0 {
+		prefetch = DefaultPrefetch
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		s.closeConn()
+		return fmt.Errorf("rmq: set prefetch: %w", err)
+	}
+
+	// Shutdown checks consuming under the same lock, so it either stops a
+	// Start that has not consumed yet or cancels a consumer whose workers
+	// are already counted.
+	s.mu.Lock()
+	if s.stopping.Load() {
+		s.mu.Unlock()
+		s.closeConn()
+		return nil
+	}
+	deliveries, err := ch.Consume(s.Topology.Queue, s.tag, false, false, false, false, nil)
+	if err != nil {
+		s.mu.Unlock()
+		s.closeConn()
+		return fmt.Errorf("rmq: consume %s: %w", s.Topology.Queue, err)
+	}
+	s.workers.Add(prefetch)
+	s.consuming = true
+	s.mu.Unlock()
+
+	slog.Info("RabbitMQ server running", "queue", s.Topology.Queue, "prefetch", prefetch)
+	for range prefetch {
+		go func() {
+			defer s.workers.Done()
+			for d := range deliveries {
+				s.handle(d)
+			}
+		}()
+	}
+	s.workers.Wait()
+
+	if s.stopping.Load() {
+		return nil
+	}
+	s.closeConn()
+	return errors.New("rmq: delivery channel closed unexpectedly")
+}
+
+// declare sets up the exchange, queue, bindings and dead-letter routing.
+func (s *Server) declare(ch rmq.Channel) error {
+	t := s.Topology
+	args := amqp.Table{}
+	if t.DeadLetterExchange != "" {
+		dlq := t.Queue + ".dlq"
+		if err := ch.ExchangeDeclare(t.DeadLetterExchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("rmq: declare dead-letter exchange %s: %w", t.DeadLetterExchange, err)
+		}
+		if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("rmq: declare dead-letter queue %s: %w", dlq, err)
+		}
+		if err := ch.QueueBind(dlq, t.Queue, t.DeadLetterExchange, false, nil); err != nil {
+			return fmt.Errorf("rmq: bind dead-letter queue %s: %w", dlq, err)
+		}
+		args["x-dead-letter-exchange"] = t.DeadLetterExchange
+		args["x-dead-letter-routing-key"] = t.Queue
+	}
+
+	if t.Exchange != "" {
+		kind := t.ExchangeType
+		if kind == "" {
+			kind = amqp.ExchangeTopic
+		}
+		if err := ch.ExchangeDeclare(t.Exchange, kind, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("rmq: declare exchange %s: %w", t.Exchange, err)
+		}
+	}
+	if _, err := ch.QueueDeclare(t.Queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("rmq: declare queue %s: %w", t.Queue, err)
+	}
+	if t.Exchange != "" {
+		keys := t.RoutingKeys
+		if len(keys) == 0 {
+			keys = []string{t.Queue}
+		}
+		for _, key := range keys {
+			if err := ch.QueueBind(t.Queue, key, t.Exchange, false, nil); err != nil {
+				return fmt.Errorf("rmq: bind %s to %s with %q: %w", t.Queue, t.Exchange, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handle runs the handler for one delivery with trace and request context
+// restored from its headers, then acks or nacks it.
+func (s *Server) handle(d amqp.Delivery) {
+	if d.Headers == nil {
+		d.Headers = amqp.Table{}
+	}
+	ctx := rmq.Extract(context.Background(), d.Headers)
+	reqID := rmq.HeadersCarrier(d.Headers).Get(rmq.HeaderRequestID)
+	if reqID == "" {
+		reqID = uuid.NewString()
+	}
+	ctx = observability.WithRequestData(ctx, reqID, "", "")
+
+	ctx, span := otel.Tracer("gossiper/rmq").Start(ctx, s.Topology.Queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", d.Exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", d.RoutingKey),
+		),
+	)
+	defer span.End()
+
+	log := observability.LoggerFromContext(ctx, nil)
+	err := s.run(ctx, d)
+	if err == nil {
+		if ackErr := d.Ack(false); ackErr != nil {
+			log.Error("rmq ack failed", slog.String("queue", s.Topology.Queue), slog.String("error", ackErr.Error()))
+		}
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	var rq requeueError
+	requeue := errors.As(err, &rq)
+	log.Error("rmq message handling failed",
+		slog.String("queue", s.Topology.Queue),
+		slog.String("routing_key", d.RoutingKey),
+		slog.Bool("requeue", requeue),
+		slog.String("error", err.Error()),
+	)
+	if nackErr := d.Nack(false, requeue); nackErr != nil {
+		log.Error("rmq nack failed", slog.String("queue", s.Topology.Queue), slog.String("error", nackErr.Error()))
+	}
+}
+
+// run calls the handler, turning a panic into an error so one bad message
+// cannot take down the consumer.
+func (s *Server) run(ctx context.Context, d amqp.Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("recovered from panic in RabbitMQ handler",
+				slog.String("queue", s.Topology.Queue),
+				slog.Any("panic", r),
+				slog.String("stack", string(debug.Stack())),
+			)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.Handler(ctx, d)
+}
+
+func (s *Server) Stop() error {
+	return s.Shutdown(context.Background())
+}
+
+// Shutdown cancels the consumer, waits for in-flight handlers until ctx
+// expires, then closes the channel and connection. Unacknowledged
+// deliveries are redelivered by the broker.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping.Store(true)
+	ch, consuming := s.ch, s.consuming
+	s.mu.Unlock()
+	if ch == nil || !consuming {
+		// Start has not consumed yet and returns once it sees stopping.
+		return nil
+	}
+	if err := ch.Cancel(s.tag, false); err != nil {
+		slog.Warn("rmq consumer cancel failed", "queue", s.Topology.Queue, "error", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.closeConn()
+		return ctx.Err()
+	}
+	slog.Info("RabbitMQ server stopped", "queue", s.Topology.Queue)
+	return s.closeConn()
+}
+
+func (s *Server) closeConn() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	if s.ch != nil {
+		errs = append(errs, s.ch.Close())
+		s.ch = nil
+	}
+	s.consuming = false
+	if s.conn != nil && !s.conn.IsClosed() {
+		errs = append(errs, s.conn.Close())
+	}
+	s.conn = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,230 @@
+package rmq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/rmq/rmqtest"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startServer runs s in the background and waits until it consumes.
+func startServer(t *testing.T, broker *rmqtest.Broker, s *Server) <-chan error {
+	t.Helper()
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start() }()
+	deadline := time.Now().Add(2 * time.Second)
+	for !broker.Consuming(s.Topology.Queue) {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start consuming")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Cleanup(func() { s.Stop() })
+	return errCh
+}
+
+func nextOutcome(t *testing.T, broker *rmqtest.Broker) rmqtest.Outcome {
+	t.Helper()
+	select {
+	case o := <-broker.Outcomes:
+		return o
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery was not settled")
+		return rmqtest.Outcome{}
+	}
+}
+
+func TestStartDeclaresTopology(t *testing.T) {
+	broker := rmqtest.NewBroker()
+	s := New("amqp://fake", Topology{
+		Exchange:           "orders",
+		Queue:              "billing",
+		RoutingKeys:        []string{"order.created", "order.paid"},
+		DeadLetterExchange: "orders.dlx",
+		Prefetch:           4,
+	}, func(context.Context, amqp.Delivery) error { return nil }, WithDialer(broker.Dial))
+	startServer(t, broker, s)
+
+	if kind := broker.ExchangeKind("orders"); kind != amqp.ExchangeTopic {
+		t.Errorf("exchange kind = %q, want %q", kind, amqp.ExchangeTopic)
+	}
+	if kind := broker.ExchangeKind("orders.dlx"); kind != amqp.ExchangeDirect {
+		t.Errorf("dead-letter exchange kind = %q, want %q", kind, amqp.ExchangeDirect)
+	}
+	args, ok := broker.QueueArgs("billing")
+	if !ok {
+		t.Fatal("queue billing not declared")
+	}
+	if args["x-dead-letter-exchange"] != "orders.dlx" || args["x-dead-letter-routing-key"] != "billing" {
+		t.Errorf("queue args = %v, want dead-letter routing to orders.dlx/billing", args)
+	}
+	if _, ok := broker.QueueArgs("billing.dlq"); !ok {
+		t.Error("dead-letter queue billing.dlq not declared")
+	}
+
+	want := map[rmqtest.Binding]bool{
+		{Queue: "billing.dlq", Key: "billing", Exchange: "orders.dlx"}: true,
+		{Queue: "billing", Key: "order.created", Exchange: "orders"}:   true,
+		{Queue: "billing", Key: "order.paid", Exchange: "orders"}:      true,
+	}
+	got := broker.Bindings()
+	if len(got) != len(want) {
+		t.Fatalf("bindings = %v, want %d", got, len(want))
+	}
+	for _, b := range got {
+		if !want[b] {
+			t.Errorf("unexpected binding %+v", b)
+		}
+	}
+	if p := broker.Prefetch(); p != 4 {
+		t.Errorf("prefetch = %d, want 4", p)
+	}
+}
+
+func TestHandlerOutcomeSettlesDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+		want    rmqtest.Outcome
+	}{
+		{"success acks", func(context.Context, amqp.Delivery) error { return nil }, rmqtest.Outcome{Ack: true}},
+		{"error dead-letters", func(context.Context, amqp.Delivery) error { return errors.New("boom") }, rmqtest.Outcome{}},
+		{"requeue error requeues", func(context.Context, amqp.Delivery) error { return Requeue(errors.New("busy")) }, rmqtest.Outcome{Requeue: true}},
+		{"panic dead-letters", func(context.Context, amqp.Delivery) error { panic("bad message") }, rmqtest.Outcome{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := rmqtest.NewBroker()
+			s := New("amqp://fake", Topology{Queue: "jobs"}, tt.handler, WithDialer(broker.Dial))
+			startServer(t, broker, s)
+
+			tag, err := broker.Deliver("jobs", amqp.Publishing{Body: []byte("{}")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := nextOutcome(t, broker)
+			tt.want.Tag = tag
+			if got != tt.want {
+				t.Errorf("outcome = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerReceivesTraceAndRequestID(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	type seen struct {
+		traceID   trace.TraceID
+		requestID string
+	}
+	got := make(chan seen, 1)
+	broker := rmqtest.NewBroker()
+	s := New("amqp://fake", Topology{Queue: "jobs"}, func(ctx context.Context, _ amqp.Delivery) error {
+		got <- seen{trace.SpanContextFromContext(ctx).TraceID(), observability.RequestID(ctx)}
+		return nil
+	}, WithDialer(broker.Dial))
+	startServer(t, broker, s)
+
+	headers := amqp.Table{
+		"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"x-request-id": "req-42",
+	}
+	if _, err := broker.Deliver("jobs", amqp.Publishing{Headers: headers}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-got:
+		if s.traceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("trace id = %s, want the one from traceparent", s.traceID)
+		}
+		if s.requestID != "req-42" {
+			t.Errorf("request id = %q, want req-42", s.requestID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not called")
+	}
+}
+
+func TestShutdownWaitsForInFlightHandler(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	broker := rmqtest.NewBroker()
+	s := New("amqp://fake", Topology{Queue: "jobs"}, func(context.Context, amqp.Delivery) error {
+		close(started)
+		<-release
+		return nil
+	}, WithDialer(broker.Dial))
+	errCh := startServer(t, broker, s)
+
+	if _, err := broker.Deliver("jobs", amqp.Publishing{}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Shutdown(context.Background()) }()
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown returned before the handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	if err := <-stopped; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if o := nextOutcome(t, broker); !o.Ack {
+		t.Errorf("in-flight delivery outcome = %+v, want ack", o)
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("Start returned %v after Shutdown, want nil", err)
+	}
+}
+
+func TestShutdownBeforeStartStopsStart(t *testing.T) {
+	broker := rmqtest.NewBroker()
+	s := New("amqp://fake", Topology{Queue: "jobs"}, func(context.Context, amqp.Delivery) error { return nil }, WithDialer(broker.Dial))
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start() }()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("Start returned %v after Shutdown, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start kept consuming after Shutdown")
+	}
+	if broker.Consuming("jobs") {
+		t.Error("consumer registered after Shutdown")
+	}
+}
+
+func TestStartFailsWhenBrokerDropsConnection(t *testing.T) {
+	broker := rmqtest.NewBroker()
+	s := New("amqp://fake", Topology{Queue: "jobs"}, func(context.Context, amqp.Delivery) error { return nil }, WithDialer(broker.Dial))
+	errCh := startServer(t, broker, s)
+
+	broker.CloseConnections()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("Start returned nil after the broker dropped the connection")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return after the broker dropped the connection")
+	}
+}
@@ -1,27 +1,127 @@
 package servers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
 )
 
+// DefaultShutdownTimeout bounds how long Run waits for all servers to drain
+// once a shutdown has been requested.
+const DefaultShutdownTimeout = 30 * time.Second
+
 type Server interface {
 	Start() error
 	Stop() error
 }
 
+// contextStopper is implemented by servers that can bound their graceful
+// shutdown by a deadline (for example by force-closing remaining
+// connections once ctx expires). Run prefers it over Stop when available.
+type contextStopper interface {
+	Shutdown(ctx context.Context) error
+}
+
+// StartPolicy decides how Run reacts when a server fails to start or exits
+// before shutdown was requested.
+type StartPolicy int
+
+const (
+	// AllOrNothing stops every other server and makes Run return the
+	// failure, so a half-started process exits instead of passing liveness.
+	AllOrNothing StartPolicy = iota
+	// BestEffort logs the failure and keeps the remaining servers running;
+	// the error is still included in Run's result.
+	BestEffort
+)
+
+// healthRegistrar is implemented by servers that can expose the manager's
+// health registry (the gRPC health service, /healthz and /readyz routes).
+type healthRegistrar interface {
+	RegisterHealth(registry *health.Registry)
+}
+
 type ServerManager struct {
-	servers []Server
+	servers         []Server
+	components      []namedComponent
+	health          *health.Registry
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	startPolicy     StartPolicy
+}
+
+// Option configures a ServerManager.
+type Option func(*ServerManager)
+
+// WithShutdownTimeout sets the drain deadline Run applies when stopping
+// servers. Non-positive values fall back to DefaultShutdownTimeout.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(sm *ServerManager) {
+		if d > 0 {
+			sm.shutdownTimeout = d
+		}
+	}
+}
+
+// WithDrainDelay makes Run wait d between marking the process as draining
+// and stopping servers, so readiness probes and health watchers see
+// NOT_SERVING while the listeners still accept connections. The delay is
+// not counted against the shutdown timeout. The default is no delay.
+func WithDrainDelay(d time.Duration) Option {
+	return func(sm *ServerManager) {
+		if d > 0 {
+			sm.drainDelay = d
+		}
+	}
+}
+
+// WithStartPolicy selects how Run handles server start failures.
+// The default is AllOrNothing.
+func WithStartPolicy(p StartPolicy) Option {
+	return func(sm *ServerManager) {
+		sm.startPolicy = p
+	}
 }
 
-func NewServerManager() *ServerManager {
-	return &ServerManager{}
+func NewServerManager(opts ...Option) *ServerManager {
+	sm := &ServerManager{
+		shutdownTimeout: DefaultShutdownTimeout,
+		health:          health.NewRegistry(),
+	}
+	for _, opt := range opts {
+		opt(sm)
+	}
+	return sm
 }
 
+// AddServer registers a server. Servers that support it get the manager's
+// health endpoints mounted automatically.
 func (sm *ServerManager) AddServer(server Server) {
+	if hr, ok := server.(healthRegistrar); ok {
+		hr.RegisterHealth(sm.health)
+	}
 	sm.servers = append(sm.servers, server)
 }
 
+// Health returns the registry backing readiness for every server added to
+// this manager. Register additional checks on it directly.
+func (sm *ServerManager) Health() *health.Registry {
+	return sm.health
+}
+
+// StartAll starts every server and blocks until all of them return, only
+// logging start errors. Prefer Run, which propagates failures and handles
+// shutdown signals.
 func (sm *ServerManager) StartAll() {
 
 	var wg sync.WaitGroup
@@ -45,3 +145,133 @@ func (sm *ServerManager) StopAll() {
 		}
 	}
 }
+
+// Run starts every registered component in dependency order, then every
+// server, and blocks until ctx is cancelled or the process receives
+// SIGINT/SIGTERM. It then stops the servers in reverse registration order
+// and, once they have drained, the components in reverse start order. The
+// configured shutdown timeout bounds the whole shutdown. Every start and
+// stop error is returned joined together.
+//
+// Under the default AllOrNothing policy a server whose Start fails, or
+// returns before shutdown was requested, triggers shutdown of the others
+// and its error is returned. Under BestEffort the failure is logged and the
+// remaining servers keep running.
+func (sm *ServerManager) Run(ctx context.Context) error {
+	components, err := sm.componentOrder()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := startComponents(ctx, components); err != nil {
+		return err
+	}
+
+	type exit struct {
+		index int
+		err   error
+	}
+	var stopping atomic.Bool
+	exits := make(chan exit, len(sm.servers))
+	for i, server := range sm.servers {
+		go func(i int, s Server) {
+			err := s.Start()
+			if err == nil && !stopping.Load() {
+				err = errors.New("exited unexpectedly")
+			}
+			exits <- exit{index: i, err: err}
+		}(i, server)
+	}
+
+	var errs []error
+	exited := make([]bool, len(sm.servers))
+	running := len(sm.servers)
+	recordExit := func(e exit) {
+		exited[e.index] = true
+		running--
+		if e.err == nil {
+			return
+		}
+		if stopping.Load() {
+			slog.Warn("server returned error during shutdown", "server", fmt.Sprintf("%T", sm.servers[e.index]), "error", e.err)
+			return
+		}
+		slog.Error("server failed", "server", fmt.Sprintf("%T", sm.servers[e.index]), "error", e.err)
+		errs = append(errs, fmt.Errorf("start %T: %w", sm.servers[e.index], e.err))
+	}
+
+wait:
+	for running > 0 || len(sm.servers) == 0 {
+		select {
+		case <-ctx.Done():
+			break wait
+		case e := <-exits:
+			recordExit(e)
+			if e.err != nil && sm.startPolicy == AllOrNothing {
+				break wait
+			}
+		}
+	}
+
+	stopping.Store(true)
+	sm.health.SetDraining(true)
+	if sm.drainDelay > 0 {
+		slog.Info("shutdown requested, draining before stopping servers", "delay", sm.drainDelay)
+		time.Sleep(sm.drainDelay)
+	}
+	slog.Info("shutdown requested, stopping servers", "timeout", sm.shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), sm.shutdownTimeout)
+	defer cancel()
+
+	for i := len(sm.servers) - 1; i >= 0; i-- {
+		if exited[i] {
+			continue
+		}
+		if err := stopServer(shutdownCtx, sm.servers[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+drain:
+	for running > 0 {
+		select {
+		case e := <-exits:
+			recordExit(e)
+		case <-shutdownCtx.Done():
+			errs = append(errs, fmt.Errorf("servers did not exit within %s: %w", sm.shutdownTimeout, shutdownCtx.Err()))
+			break drain
+		}
+	}
+
+	if err := stopComponents(shutdownCtx, components); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopServer stops s, returning early with an error if ctx expires first.
+func stopServer(ctx context.Context, s Server) error {
+	if cs, ok := s.(contextStopper); ok {
+		if err := cs.Shutdown(ctx); err != nil {
+			return fmt.Errorf("stop %T: %w", s, err)
+		}
+		return nil
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Stop() }()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("stop %T: %w", s, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("stop %T: %w", s, ctx.Err())
+	}
+}
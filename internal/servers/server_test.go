@@ -0,0 +1,358 @@
+package servers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/health"
+)
+
+// fakeServer blocks in Start until Stop is called and records stop order.
+type fakeServer struct {
+	name    string
+	stopped chan struct{}
+	once    sync.Once
+	stopErr error
+	block   time.Duration
+	order   *[]string
+	mu      *sync.Mutex
+}
+
+func newFakeServer(name string, order *[]string, mu *sync.Mutex) *fakeServer {
+	return &fakeServer{name: name, stopped: make(chan struct{}), order: order, mu: mu}
+}
+
+func (f *fakeServer) Start() error {
+	<-f.stopped
+	return nil
+}
+
+func (f *fakeServer) Stop() error {
+	time.Sleep(f.block)
+	f.mu.Lock()
+	*f.order = append(*f.order, f.name)
+	f.mu.Unlock()
+	f.once.Do(func() { close(f.stopped) })
+	return f.stopErr
+}
+
+func TestRun_StopsInReverseOrderOnCancel(t *testing.T) {
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+	sm := NewServerManager()
+	sm.AddServer(newFakeServer("first", &order, &mu))
+	sm.AddServer(newFakeServer("second", &order, &mu))
+	sm.AddServer(newFakeServer("third", &order, &mu))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sm.Run(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+
+	want := []string{"third", "second", "first"}
+	if len(order) != len(want) {
+		t.Fatalf("stop order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("stop order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRun_AggregatesStopErrors(t *testing.T) {
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	a := newFakeServer("a", &order, &mu)
+	a.stopErr = errA
+	b := newFakeServer("b", &order, &mu)
+	b.stopErr = errB
+
+	sm := NewServerManager()
+	sm.AddServer(a)
+	sm.AddServer(b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := sm.Run(ctx)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("expected both stop errors, got %v", err)
+	}
+}
+
+func TestRun_HonoursShutdownTimeout(t *testing.T) {
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+	slow := newFakeServer("slow", &order, &mu)
+	slow.block = time.Second
+
+	sm := NewServerManager(WithShutdownTimeout(50 * time.Millisecond))
+	sm.AddServer(slow)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := sm.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Run took %s, expected it to give up near the 50ms deadline", elapsed)
+	}
+}
+
+// failingServer returns err from Start immediately.
+type failingServer struct{ err error }
+
+func (f failingServer) Start() error { return f.err }
+func (f failingServer) Stop() error  { return nil }
+
+func TestRun_AllOrNothingStopsOthersOnStartFailure(t *testing.T) {
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+	bindErr := errors.New("address already in use")
+	healthy := newFakeServer("healthy", &order, &mu)
+
+	sm := NewServerManager()
+	sm.AddServer(healthy)
+	sm.AddServer(failingServer{err: bindErr})
+
+	done := make(chan error, 1)
+	go func() { done <- sm.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, bindErr) {
+			t.Fatalf("expected start error to propagate, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after a server failed to start")
+	}
+	if len(order) != 1 || order[0] != "healthy" {
+		t.Fatalf("expected healthy server to be stopped, stop order = %v", order)
+	}
+}
+
+func TestRun_BestEffortKeepsOthersRunning(t *testing.T) {
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+	bindErr := errors.New("address already in use")
+	healthy := newFakeServer("healthy", &order, &mu)
+
+	sm := NewServerManager(WithStartPolicy(BestEffort))
+	sm.AddServer(healthy)
+	sm.AddServer(failingServer{err: bindErr})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sm.Run(ctx) }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Run returned early under BestEffort: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	err := <-done
+	if !errors.Is(err, bindErr) {
+		t.Fatalf("expected start error in result, got %v", err)
+	}
+	if len(order) != 1 || order[0] != "healthy" {
+		t.Fatalf("stop order = %v", order)
+	}
+}
+
+func TestRun_UnexpectedExitIsAnError(t *testing.T) {
+	sm := NewServerManager()
+	sm.AddServer(failingServer{})
+
+	done := make(chan error, 1)
+	go func() { done <- sm.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a server that exited on its own")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after a server exited")
+	}
+}
+
+// recordingComponent appends "start:<name>"/"stop:<name>" to a shared log.
+type recordingComponent struct {
+	name     string
+	log      *[]string
+	mu       *sync.Mutex
+	startErr error
+}
+
+func (r recordingComponent) OnStart(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.startErr != nil {
+		return r.startErr
+	}
+	*r.log = append(*r.log, "start:"+r.name)
+	return nil
+}
+
+func (r recordingComponent) OnStop(context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.log = append(*r.log, "stop:"+r.name)
+	return nil
+}
+
+func TestRun_ComponentsWrapServersInDependencyOrder(t *testing.T) {
+	var (
+		events []string
+		mu     sync.Mutex
+	)
+	sm := NewServerManager()
+	sm.AddComponent("tenants", recordingComponent{name: "tenants", log: &events, mu: &mu}, "postgres")
+	sm.AddComponent("postgres", recordingComponent{name: "postgres", log: &events, mu: &mu})
+	sm.AddComponent("telemetry", recordingComponent{name: "telemetry", log: &events, mu: &mu})
+	sm.AddServer(newFakeServer("grpc", &events, &mu))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sm.Run(ctx) }()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"start:postgres", "start:tenants", "start:telemetry",
+		"grpc",
+		"stop:telemetry", "stop:tenants", "stop:postgres",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events = %v, want %v", events, want)
+		}
+	}
+}
+
+func TestRun_ComponentStartFailureRollsBack(t *testing.T) {
+	var (
+		events []string
+		mu     sync.Mutex
+	)
+	connErr := errors.New("connection refused")
+	sm := NewServerManager()
+	sm.AddComponent("postgres", recordingComponent{name: "postgres", log: &events, mu: &mu})
+	sm.AddComponent("cache", recordingComponent{name: "cache", log: &events, mu: &mu, startErr: connErr})
+	sm.AddServer(newFakeServer("grpc", &events, &mu))
+
+	err := sm.Run(context.Background())
+	if !errors.Is(err, connErr) {
+		t.Fatalf("expected component start error, got %v", err)
+	}
+	want := []string{"start:postgres", "stop:postgres"}
+	if len(events) != len(want) || events[0] != want[0] || events[1] != want[1] {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestComponentOrder_RejectsInvalidGraphs(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(sm *ServerManager)
+	}{
+		{name: "unknown dependency", setup: func(sm *ServerManager) {
+			sm.AddComponent("a", Hooks{}, "missing")
+		}},
+		{name: "duplicate name", setup: func(sm *ServerManager) {
+			sm.AddComponent("a", Hooks{})
+			sm.AddComponent("a", Hooks{})
+		}},
+		{name: "cycle", setup: func(sm *ServerManager) {
+			sm.AddComponent("a", Hooks{}, "b")
+			sm.AddComponent("b", Hooks{}, "a")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm := NewServerManager()
+			tt.setup(sm)
+			if _, err := sm.componentOrder(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// drainObserver records whether readiness was already draining, and when,
+// once Run stops it.
+type drainObserver struct {
+	health   *health.Registry
+	stopped  chan struct{}
+	draining bool
+	at       time.Time
+}
+
+func (d *drainObserver) Start() error {
+	<-d.stopped
+	return nil
+}
+
+func (d *drainObserver) Stop() error {
+	d.draining, d.at = d.health.Draining(), time.Now()
+	close(d.stopped)
+	return nil
+}
+
+func TestRun_DrainDelayPrecedesStop(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	sm := NewServerManager(WithDrainDelay(delay))
+	obs := &drainObserver{health: sm.Health(), stopped: make(chan struct{})}
+	sm.AddServer(obs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sm.Run(ctx) }()
+	time.Sleep(10 * time.Millisecond)
+	requested := time.Now()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !obs.draining {
+		t.Error("server stopped before readiness reported draining")
+	}
+	if waited := obs.at.Sub(requested); waited < delay {
+		t.Errorf("server stopped %s after shutdown was requested, want at least %s", waited, delay)
+	}
+}
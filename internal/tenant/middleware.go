@@ -0,0 +1,223 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// ErrNoTenant is returned by Transaction for requests that did not pass
+// through the tenant middleware.
+var ErrNoTenant = errors.New("tenant: request has no tenant database")
+
+var (
+	errTenantRequired = errors.New("tenant required")
+	errUnknownTenant  = errors.New("unknown tenant")
+)
+
+// DefaultExempt are the paths and methods the tenant middleware lets
+// through without a tenant: the gRPC health service and the HTTP health
+// probes.
+var DefaultExempt = []string{"/grpc.health.v1.Health/", "/healthz", "/readyz"}
+
+// Registry tells the middleware which tenants exist. Manager implements it.
+type Registry interface {
+	Exists(ctx context.Context, namespace string) (bool, error)
+}
+
+// Database runs fn on a connection pinned to a tenant schema. db.Database
+// implements it.
+type Database interface {
+	WithSchema(ctx context.Context, schema string, fn func(tx *gorm.DB) error) error
+}
+
+type ctxKeyScope struct{}
+
+// scope is the tenant database of a request, set by the tenant middleware.
+type scope struct {
+	db        Database
+	namespace string
+}
+
+// Transaction runs fn in a transaction on the schema of the request's
+// tenant, set by the tenant middleware. The transaction commits when fn
+// returns nil and rolls back when it returns an error; what the handler
+// later writes to the client plays no part. Each call pins a connection
+// only for its own duration, so keep calls short rather than wrapping a
+// whole stream in one.
+func Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	s, ok := ctx.Value(ctxKeyScope{}).(scope)
+	if !ok {
+		return ErrNoTenant
+	}
+	return s.db.WithSchema(ctx, s.namespace, fn)
+}
+
+// withScope returns ctx carrying the tenant and its database.
+func withScope(ctx context.Context, db Database, namespace string) context.Context {
+	ctx = observability.WithTenant(ctx, namespace)
+	return context.WithValue(ctx, ctxKeyScope{}, scope{db: db, namespace: namespace})
+}
+
+// MiddlewareOption configures FiberMiddleware and the gRPC server
+// interceptors.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	resolver Resolver
+	exempt   []string
+}
+
+func newMiddlewareConfig(opts []MiddlewareOption) middlewareConfig {
+	c := middlewareConfig{resolver: DefaultResolver, exempt: DefaultExempt}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WithResolver sets how the tenant is read from a request. The default is
+// DefaultResolver.
+func WithResolver(r Resolver) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.resolver = r
+	}
+}
+
+// WithExempt lets requests through without a tenant when their path or
+// gRPC method is one of paths or lies below it, in addition to
+// DefaultExempt. "/healthz" exempts "/healthz" and "/healthz/live" but not
+// "/healthzz"; a path ending in "/", like "/grpc.health.v1.Health/",
+// exempts everything below it.
+func WithExempt(paths ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.exempt = append(append([]string(nil), c.exempt...), paths...)
+	}
+}
+
+func (c middlewareConfig) isExempt(path string) bool {
+	for _, exempt := range c.exempt {
+		rest, ok := strings.CutPrefix(path, exempt)
+		if ok && (rest == "" || strings.HasSuffix(exempt, "/") || rest[0] == '/') {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the tenant named by src once registry confirms it exists.
+func (c middlewareConfig) resolve(ctx context.Context, registry Registry, src Source) (string, error) {
+	namespace := c.resolver(src)
+	if namespace == "" {
+		return "", errTenantRequired
+	}
+	ok, err := registry.Exists(ctx, namespace)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", errUnknownTenant
+	}
+	return namespace, nil
+}
+
+// FiberMiddleware resolves the tenant of each request and rejects requests
+// naming no tenant (400) or an unknown one (404). Handlers reach the
+// tenant's schema through Transaction.
+func FiberMiddleware(registry Registry, db Database, opts ...MiddlewareOption) fiber.Handler {
+	cfg := newMiddlewareConfig(opts)
+	return func(c *fiber.Ctx) error {
+		if cfg.isExempt(c.Path()) {
+			return c.Next()
+		}
+		ctx := c.UserContext()
+		header := func(key string) string { return c.Get(key) }
+		namespace, err := cfg.resolve(ctx, registry, Source{Header: header, Host: c.Hostname()})
+		switch {
+		case errors.Is(err, errTenantRequired):
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		case errors.Is(err, errUnknownTenant):
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		case err != nil:
+			return fiber.NewError(fiber.StatusServiceUnavailable, "tenant registry unavailable")
+		}
+		// Fiber reuses header buffers once the request completes.
+		namespace = strings.Clone(namespace)
+		c.SetUserContext(withScope(ctx, db, namespace))
+		return c.Next()
+	}
+}
+
+// grpcSource exposes the metadata of a call to a Resolver.
+func grpcSource(ctx context.Context) Source {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	return Source{Header: first, Host: first(":authority")}
+}
+
+// grpcError converts a resolve error to a gRPC status.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, errTenantRequired):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errUnknownTenant):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Unavailable, "tenant registry unavailable")
+	}
+}
+
+// GRPCServerInterceptor is the gRPC counterpart of FiberMiddleware. Calls
+// naming no tenant fail with codes.InvalidArgument, unknown tenants with
+// codes.NotFound. Register it at the tenant stage, after auth.
+func GRPCServerInterceptor(registry Registry, db Database, opts ...MiddlewareOption) grpc.UnaryServerInterceptor {
+	cfg := newMiddlewareConfig(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if cfg.isExempt(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		namespace, err := cfg.resolve(ctx, registry, grpcSource(ctx))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		return handler(withScope(ctx, db, namespace), req)
+	}
+}
+
+// GRPCStreamServerInterceptor is the streaming counterpart of
+// GRPCServerInterceptor.
+func GRPCStreamServerInterceptor(registry Registry, db Database, opts ...MiddlewareOption) grpc.StreamServerInterceptor {
+	cfg := newMiddlewareConfig(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if cfg.isExempt(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx := ss.Context()
+		namespace, err := cfg.resolve(ctx, registry, grpcSource(ctx))
+		if err != nil {
+			return grpcError(err)
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: withScope(ctx, db, namespace)})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,199 @@
+package tenant
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type fakeRegistry map[string]bool
+
+func (r fakeRegistry) Exists(_ context.Context, namespace string) (bool, error) {
+	if namespace == "broken" {
+		return false, errors.New("registry down")
+	}
+	return r[namespace], nil
+}
+
+// fakeDatabase hands fn one *gorm.DB per schema and records the schemas
+// used.
+type fakeDatabase struct {
+	schemas []string
+	txs     map[string]*gorm.DB
+}
+
+func (d *fakeDatabase) WithSchema(_ context.Context, schema string, fn func(tx *gorm.DB) error) error {
+	d.schemas = append(d.schemas, schema)
+	if d.txs == nil {
+		d.txs = map[string]*gorm.DB{}
+	}
+	if d.txs[schema] == nil {
+		d.txs[schema] = &gorm.DB{}
+	}
+	return fn(d.txs[schema])
+}
+
+func TestResolvers(t *testing.T) {
+	enc := base64.RawURLEncoding.EncodeToString
+	token := enc([]byte(`{"alg":"HS256"}`)) + "." + enc([]byte(`{"tenant":"initech"}`)) + ".c2ln"
+	header := func(key, value string) func(string) string {
+		return func(k string) string {
+			if k == key {
+				return value
+			}
+			return ""
+		}
+	}
+
+	tests := []struct {
+		name     string
+		resolver Resolver
+		src      Source
+		want     string
+	}{
+		{"header", FromHeader("namespace"), Source{Header: header("namespace", " acme ")}, "acme"},
+		{"no header", FromHeader("namespace"), Source{}, ""},
+		{"subdomain", FromSubdomain("api.example.com"), Source{Host: "acme.api.example.com:443"}, "acme"},
+		{"base domain", FromSubdomain("api.example.com"), Source{Host: "api.example.com"}, ""},
+		{"nested subdomain", FromSubdomain("api.example.com"), Source{Host: "a.b.api.example.com"}, ""},
+		{"other domain", FromSubdomain("api.example.com"), Source{Host: "acme.evil.com"}, ""},
+		{"jwt claim", FromJWTClaim("tenant"), Source{Header: header("Authorization", "Bearer "+token)}, "initech"},
+		{"first of", FirstOf(FromHeader("namespace"), FromSubdomain("example.com")), Source{Host: "globex.example.com"}, "globex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resolver(tt.src); got != tt.want {
+				t.Errorf("tenant = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFiberMiddleware(t *testing.T) {
+	db := &fakeDatabase{}
+	app := fiber.New()
+	app.Use(FiberMiddleware(fakeRegistry{"acme": true}, db, WithExempt("/metrics")))
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		err := Transaction(c.UserContext(), func(*gorm.DB) error { return nil })
+		if !errors.Is(err, ErrNoTenant) {
+			t.Errorf("Transaction on an exempt route: %v, want ErrNoTenant", err)
+		}
+		return c.SendString("ok")
+	})
+	app.Get("/orders", func(c *fiber.Ctx) error {
+		err := Transaction(c.UserContext(), func(tx *gorm.DB) error {
+			if tx != db.txs["acme"] {
+				t.Error("handler got a database not scoped to acme")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if got := observability.Tenant(c.UserContext()); got != "acme" {
+			t.Errorf("context tenant = %q, want acme", got)
+		}
+		return c.SendString("ok")
+	})
+	app.Get("/ping", func(c *fiber.Ctx) error {
+		return c.SendString("pong")
+	})
+
+	tests := []struct {
+		path, tenant string
+		want         int
+	}{
+		{"/orders", "acme", fiber.StatusOK},
+		{"/ping", "acme", fiber.StatusOK},
+		{"/orders", "", fiber.StatusBadRequest},
+		{"/orders", "globex", fiber.StatusNotFound},
+		{"/orders", "broken", fiber.StatusServiceUnavailable},
+		{"/healthz", "", fiber.StatusOK},
+		{"/healthzanything", "", fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.tenant != "" {
+			req.Header.Set("Namespace", tt.tenant)
+		}
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.want {
+			t.Errorf("%s with tenant %q: status %d, want %d", tt.path, tt.tenant, res.StatusCode, tt.want)
+		}
+	}
+	// Only the handler that queries opens a transaction.
+	if len(db.schemas) != 1 || db.schemas[0] != "acme" {
+		t.Errorf("WithSchema called for %v, want [acme]", db.schemas)
+	}
+}
+
+func TestIsExempt(t *testing.T) {
+	cfg := newMiddlewareConfig([]MiddlewareOption{WithExempt("/metrics")})
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/healthz", true},
+		{"/healthz/live", true},
+		{"/readyz", true},
+		{"/metrics", true},
+		{"/grpc.health.v1.Health/Check", true},
+		{"/healthzanything", false},
+		{"/readyzz", false},
+		{"/metrics-admin", false},
+		{"/grpc.health.v1.HealthX/Check", false},
+		{"/orders", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.isExempt(tt.path); got != tt.want {
+			t.Errorf("isExempt(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGRPCServerInterceptor(t *testing.T) {
+	db := &fakeDatabase{}
+	interceptor := GRPCServerInterceptor(fakeRegistry{"acme": true}, db)
+
+	call := func(method string, md metadata.MD) (*gorm.DB, error) {
+		var tx *gorm.DB
+		_, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil,
+			&grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, _ any) (any, error) {
+				err := Transaction(ctx, func(t *gorm.DB) error {
+					tx = t
+					return nil
+				})
+				return nil, err
+			})
+		return tx, err
+	}
+
+	tx, err := call("/svc.Svc/Call", metadata.Pairs("namespace", "acme"))
+	if err != nil || tx == nil || tx != db.txs["acme"] {
+		t.Errorf("acme call: tx %p, err %v", tx, err)
+	}
+	if _, err := call("/svc.Svc/Call", metadata.MD{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing tenant: %v, want InvalidArgument", err)
+	}
+	if _, err := call("/svc.Svc/Call", metadata.Pairs("namespace", "globex")); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown tenant: %v, want NotFound", err)
+	}
+	if _, err := call("/grpc.health.v1.Health/Check", metadata.MD{}); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("health check: %v, want it let through with no tenant database", err)
+	}
+}
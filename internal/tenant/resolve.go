@@ -0,0 +1,78 @@
+package tenant
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+)
+
+// Source is what a Resolver can inspect about a request.
+type Source struct {
+	// Header returns the first value of an HTTP header or gRPC metadata
+	// key, looked up case-insensitively, or "".
+	Header func(key string) string
+	// Host is the HTTP Host header or the gRPC :authority.
+	Host string
+}
+
+func (s Source) header(key string) string {
+	if s.Header == nil {
+		return ""
+	}
+	return s.Header(key)
+}
+
+// Resolver returns the tenant named by a request, or "" when it names none.
+type Resolver func(s Source) string
+
+// DefaultResolver reads the tenant from the header and metadata key the
+// observability middleware logs it from.
+var DefaultResolver = FromHeader(observability.DefaultTenantKey)
+
+// FirstOf returns the tenant of the first resolver that finds one.
+func FirstOf(resolvers ...Resolver) Resolver {
+	return func(s Source) string {
+		for _, r := range resolvers {
+			if t := r(s); t != "" {
+				return t
+			}
+		}
+		return ""
+	}
+}
+
+// FromHeader reads the tenant from an HTTP header or gRPC metadata key.
+func FromHeader(key string) Resolver {
+	return func(s Source) string {
+		return strings.TrimSpace(s.header(key))
+	}
+}
+
+// FromSubdomain reads the tenant from the label in front of baseDomain, so
+// "acme.api.example.com" is tenant "acme" for base "api.example.com".
+// Hosts outside baseDomain, or more than one label below it, name none.
+func FromSubdomain(baseDomain string) Resolver {
+	suffix := "." + strings.ToLower(strings.Trim(baseDomain, "."))
+	return func(s Source) string {
+		host := s.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		label, ok := strings.CutSuffix(strings.ToLower(host), suffix)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			return ""
+		}
+		return label
+	}
+}
+
+// FromJWTClaim reads the tenant from a string claim of the bearer token.
+// The token is decoded, not verified: register the tenant middleware after
+// the auth stage that verifies it.
+func FromJWTClaim(claim string) Resolver {
+	extract := observability.JWTClaim(claim)
+	return func(s Source) string {
+		return extract(observability.Credentials{Header: s.Header})
+	}
+}
@@ -1,61 +1,128 @@
 package tenant
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/generic"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ITenantManager defines the interface for tenant management
 type ITenantManager interface {
-	EncryptTenant(tenant tenant) (string, error)
+	EncryptTenant(tenant TenantInfo) (string, error)
 	SyncTenants(encryptedTenants *[]EncryptedTenant) error
-	loadTenants(encryptedTenants *[]EncryptedTenant) error
-	seedTenants() error
-	decryptTenant(et EncryptedTenant) (tenant, error)
+	List(ctx context.Context) ([]TenantInfo, error)
+	Get(ctx context.Context, namespace string) (TenantInfo, error)
+	Exists(ctx context.Context, namespace string) (bool, error)
+	SetSchemaVersion(ctx context.Context, namespace string, version int) error
+	loadTenants(ctx context.Context, encryptedTenants *[]EncryptedTenant) ([]TenantInfo, error)
+	seedTenants(ctx context.Context, tenants []TenantInfo) error
+	decryptTenant(et EncryptedTenant) (TenantInfo, error)
 }
 
+// ErrTenantNotFound is returned for namespaces missing from the registry.
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// RegistryTable is the table the registry is persisted in. It is
+// schema-qualified so that a connection left on a tenant's search_path
+// still finds it.
+const RegistryTable = "public.gossiper_tenants"
+
 // Manager implements ITenantManager for managing tenants
 type Manager struct {
-	db      *gorm.DB
-	tenants []tenant
-	secret  []byte // 32-byte KEY for AES-256 encryption
+	db     *gorm.DB
+	secret []byte // 32-byte KEY for AES-256 encryption
+
+	mu       sync.RWMutex
+	migrated bool
+	active   map[string]time.Time // namespaces known to be StatusActive, until when
+	unknown  map[string]time.Time // namespaces Exists found inactive, until when
+	seedErrs []error
+	now      func() time.Time
 }
 
+const (
+	// activeTTL is how long Exists trusts a cached active tenant before
+	// checking the registry again, so tenants deactivated by another
+	// instance stop passing the middleware.
+	activeTTL = time.Minute
+	// unknownTTL is how long Exists remembers a namespace that is not an
+	// active tenant, so repeated requests for it do not each query the
+	// registry.
+	unknownTTL = 5 * time.Second
+	// maxUnknown bounds the negative cache against requests naming random
+	// tenants.
+	maxUnknown = 1024
+)
+
 // EncryptedTenant represents a tenant with encrypted credentials
 type EncryptedTenant struct {
 	Namespace   string // Database schema name
 	Credentials string // AES-256 encrypted string "username:password"
 }
 
+// Status is the provisioning state of a tenant in the registry.
+type Status string
+
+const (
+	// StatusPending tenants are registered but not seeded yet.
+	StatusPending Status = "pending"
+	// StatusActive tenants have their schema and user in place.
+	StatusActive Status = "active"
+	// StatusFailed tenants failed to seed during the last sync.
+	StatusFailed Status = "failed"
+)
+
+// TenantInfo describes a tenant of the registry.
+type TenantInfo struct {
+	Namespace string // Database schema name
+	Username  string
+	// Password is only read by EncryptTenant; List and Get leave it empty.
+	Password string
+	Status   Status
+	// SchemaVersion is the migration version recorded with
+	// SetSchemaVersion, 0 until then.
+	SchemaVersion int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// record is a row of RegistryTable. Credentials stay encrypted at rest.
+type record struct {
+	Namespace     string `gorm:"primaryKey;size:63"`
+	Credentials   string `gorm:"not null"`
+	Status        Status `gorm:"size:16;not null"`
+	SchemaVersion int    `gorm:"not null;default:0"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (record) TableName() string { return RegistryTable }
+
 // Data TenantData type to hold the data in [username:password] format
 type data string
 
 // ToTenantData converts a tenant to TenantData
-func (t tenant) toTenantData() data {
-	return data(fmt.Sprintf("%s:%s", t.username, t.password))
-}
-
-// tenant represents a tenant with plain text credentials
-type tenant struct {
-	database string // Schema name / namespace name
-	username string
-	password string
+func (t TenantInfo) toTenantData() data {
+	return data(fmt.Sprintf("%s:%s", t.Username, t.Password))
 }
 
 // ToTenant converts TenantData back to a tenant
-func (td data) toTenant(database string) (tenant, error) {
+func (td data) toTenant(database string) (TenantInfo, error) {
 	parts := strings.Split(string(td), ":")
 	if len(parts) != 2 {
-		return tenant{}, errors.New("invalid tenant data format")
+		return TenantInfo{}, errors.New("invalid tenant data format")
 	}
-	return tenant{
-		database: database,
-		username: parts[0],
-		password: parts[1],
+	return TenantInfo{
+		Namespace: database,
+		Username:  parts[0],
+		Password:  parts[1],
 	}, nil
 }
 
@@ -65,55 +132,240 @@ func NewTenantManager(db *gorm.DB, secret string) (*Manager, error) {
 		return nil, errors.New("secret must be 32 bytes")
 	}
 	return &Manager{
-		db:     db,
-		secret: []byte(secret),
+		db:      db,
+		secret:  []byte(secret),
+		active:  make(map[string]time.Time),
+		unknown: make(map[string]time.Time),
+		now:     time.Now,
 	}, nil
 }
 
-// LoadTenants loads and decrypts tenants from encrypted data
-func (tm *Manager) loadTenants(encryptedTenants *[]EncryptedTenant) error {
+// ensureRegistry creates RegistryTable, or adds missing columns, once per
+// Manager.
+func (tm *Manager) ensureRegistry(ctx context.Context) error {
+	tm.mu.RLock()
+	migrated := tm.migrated
+	tm.mu.RUnlock()
+	if migrated {
+		return nil
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.migrated {
+		return nil
+	}
+	if err := tm.db.WithContext(ctx).AutoMigrate(&record{}); err != nil {
+		return fmt.Errorf("failed to create tenant registry: %w", err)
+	}
+	tm.migrated = true
+	return nil
+}
+
+// LoadTenants decrypts tenants and registers them. Tenants already in the
+// registry get their credentials updated, so loading is idempotent.
+func (tm *Manager) loadTenants(ctx context.Context, encryptedTenants *[]EncryptedTenant) ([]TenantInfo, error) {
+	tenants := make([]TenantInfo, 0, len(*encryptedTenants))
 	for _, et := range *encryptedTenants {
 		t, err := tm.decryptTenant(et)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		rec := record{Namespace: et.Namespace, Credentials: et.Credentials, Status: StatusPending}
+		err = tm.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "namespace"}},
+			DoUpdates: clause.AssignmentColumns([]string{"credentials", "updated_at"}),
+		}).Create(&rec).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to register tenant %s: %w", et.Namespace, err)
 		}
-		tm.tenants = append(tm.tenants, t)
+		tenants = append(tenants, t)
 	}
-	return nil
+	return tenants, nil
 }
 
-// SeedTenants creates schemas and users in the database for all loaded tenants
-func (tm *Manager) seedTenants() error {
-	for _, t := range tm.tenants {
+// SeedTenants creates schemas and users in the database for the given
+// tenants and records whether each one is now active
+func (tm *Manager) seedTenants(ctx context.Context, tenants []TenantInfo) error {
+	var seedErrs []error
+	for _, t := range tenants {
+		status := StatusActive
 		if err := tm.seedSingleTenant(t); err != nil {
 			log.Printf("Failed to seed tenant: %v", err)
+			seedErrs = append(seedErrs, err)
+			status = StatusFailed
+		}
+		if err := tm.setStatus(ctx, t.Namespace, status); err != nil {
+			return err
 		}
 	}
+	tm.mu.Lock()
+	tm.seedErrs = seedErrs
+	tm.mu.Unlock()
+	return nil
+}
+
+func (tm *Manager) setStatus(ctx context.Context, namespace string, status Status) error {
+	err := tm.db.WithContext(ctx).Model(&record{}).
+		Where("namespace = ?", namespace).
+		Update("status", status).Error
+	if err != nil {
+		return fmt.Errorf("failed to update tenant %s: %w", namespace, err)
+	}
+	tm.mu.Lock()
+	if status == StatusActive {
+		tm.active[namespace] = tm.now().Add(activeTTL)
+		delete(tm.unknown, namespace)
+	} else {
+		delete(tm.active, namespace)
+	}
+	tm.mu.Unlock()
 	return nil
 }
 
+// Exists reports whether namespace is an active tenant of the registry.
+// Active tenants are cached for a minute, and other namespaces are
+// remembered for a few seconds, so most calls do not reach the database.
+func (tm *Manager) Exists(ctx context.Context, namespace string) (bool, error) {
+	tm.mu.RLock()
+	activeUntil, active := tm.active[namespace]
+	unknownUntil, unknown := tm.unknown[namespace]
+	tm.mu.RUnlock()
+	now := tm.now()
+	if active && now.Before(activeUntil) {
+		return true, nil
+	}
+	if unknown && now.Before(unknownUntil) {
+		return false, nil
+	}
+	if err := tm.ensureRegistry(ctx); err != nil {
+		return false, err
+	}
+	var count int64
+	err := tm.db.WithContext(ctx).Model(&record{}).
+		Where("namespace = ? AND status = ?", namespace, StatusActive).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if count == 0 {
+		delete(tm.active, namespace)
+		if len(tm.unknown) >= maxUnknown {
+			clear(tm.unknown)
+		}
+		tm.unknown[namespace] = tm.now().Add(unknownTTL)
+		return false, nil
+	}
+	tm.active[namespace] = tm.now().Add(activeTTL)
+	return true, nil
+}
+
+// Get returns the registered tenant namespace, or ErrTenantNotFound.
+func (tm *Manager) Get(ctx context.Context, namespace string) (TenantInfo, error) {
+	if err := tm.ensureRegistry(ctx); err != nil {
+		return TenantInfo{}, err
+	}
+	var rec record
+	err := tm.db.WithContext(ctx).Where("namespace = ?", namespace).Take(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return TenantInfo{}, fmt.Errorf("%w: %s", ErrTenantNotFound, namespace)
+	}
+	if err != nil {
+		return TenantInfo{}, err
+	}
+	return tm.info(rec)
+}
+
+// List returns every registered tenant, ordered by namespace.
+func (tm *Manager) List(ctx context.Context) ([]TenantInfo, error) {
+	if err := tm.ensureRegistry(ctx); err != nil {
+		return nil, err
+	}
+	var recs []record
+	if err := tm.db.WithContext(ctx).Order("namespace").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	tenants := make([]TenantInfo, 0, len(recs))
+	for _, rec := range recs {
+		t, err := tm.info(rec)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, nil
+}
+
+// SetSchemaVersion records the migration version the schema of namespace
+// is at, e.g. after MigrateTenants.
+func (tm *Manager) SetSchemaVersion(ctx context.Context, namespace string, version int) error {
+	if err := tm.ensureRegistry(ctx); err != nil {
+		return err
+	}
+	res := tm.db.WithContext(ctx).Model(&record{}).
+		Where("namespace = ?", namespace).
+		Update("schema_version", version)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrTenantNotFound, namespace)
+	}
+	return nil
+}
+
+// info converts a registry row to TenantInfo, without the password.
+func (tm *Manager) info(rec record) (TenantInfo, error) {
+	t, err := tm.decryptTenant(EncryptedTenant{Namespace: rec.Namespace, Credentials: rec.Credentials})
+	if err != nil {
+		return TenantInfo{}, err
+	}
+	t.Password = ""
+	t.Status = rec.Status
+	t.SchemaVersion = rec.SchemaVersion
+	t.CreatedAt = rec.CreatedAt
+	t.UpdatedAt = rec.UpdatedAt
+	return t, nil
+}
+
+// HealthCheck reports an error if the database is unreachable or any
+// tenant failed to seed during the last sync.
+func (tm *Manager) HealthCheck(ctx context.Context) error {
+	sqlDB, err := tm.db.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return err
+	}
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return errors.Join(tm.seedErrs...)
+}
+
 // seedSingleTenant creates schema, user and grants privileges for a single tenant
-func (tm *Manager) seedSingleTenant(t tenant) error {
-	if t.database == "" || t.username == "" || t.password == "" {
+func (tm *Manager) seedSingleTenant(t TenantInfo) error {
+	if t.Namespace == "" || t.Username == "" || t.Password == "" {
 		return errors.New("tenant data is incomplete")
 	}
 
 	// database/username are SQL identifiers, not values - quote them properly
 	// instead of interpolating raw strings into CREATE SCHEMA/USER/GRANT.
-	schemaIdent, err := generic.QuotePGIdentifier(t.database)
+	schemaIdent, err := generic.QuotePGIdentifier(t.Namespace)
 	if err != nil {
-		return fmt.Errorf("invalid tenant schema name %q: %w", t.database, err)
+		return fmt.Errorf("invalid tenant schema name %q: %w", t.Namespace, err)
 	}
-	userIdent, err := generic.QuotePGIdentifier(t.username)
+	userIdent, err := generic.QuotePGIdentifier(t.Username)
 	if err != nil {
-		return fmt.Errorf("invalid tenant username %q: %w", t.username, err)
+		return fmt.Errorf("invalid tenant username %q: %w", t.Username, err)
 	}
-	passwordLiteral := generic.EscapePGStringLiteral(t.password)
+	passwordLiteral := generic.EscapePGStringLiteral(t.Password)
 
 	// Create schema if it doesn't exist
 	createSchemaSQL := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", schemaIdent)
 	if err := tm.db.Exec(createSchemaSQL).Error; err != nil {
-		return fmt.Errorf("failed to create schema %s: %v", t.database, err)
+		return fmt.Errorf("failed to create schema %s: %v", t.Namespace, err)
 	}
 
 	// Create user with password
@@ -124,24 +376,24 @@ func (tm *Manager) seedSingleTenant(t tenant) error {
 						CREATE USER %s WITH PASSWORD %s;
 					END IF;
 				END $$;`,
-		generic.EscapePGStringLiteral(t.username), userIdent, passwordLiteral,
+		generic.EscapePGStringLiteral(t.Username), userIdent, passwordLiteral,
 	)
 	if err := tm.db.Exec(createUserSQL).Error; err != nil {
-		return fmt.Errorf("failed to create user %s: %v", t.username, err)
+		return fmt.Errorf("failed to create user %s: %v", t.Username, err)
 	}
 
 	// Grant all privileges on schema to user
 	grantPrivilegesSQL := fmt.Sprintf("GRANT ALL PRIVILEGES ON SCHEMA %s TO %s", schemaIdent, userIdent)
 	if err := tm.db.Exec(grantPrivilegesSQL).Error; err != nil {
-		return fmt.Errorf("failed to grant privileges on schema %s to user %s: %v", t.database, t.username, err)
+		return fmt.Errorf("failed to grant privileges on schema %s to user %s: %v", t.Namespace, t.Username, err)
 	}
 
-	log.Printf("Successfully seeded tenant: %s", t.database)
+	log.Printf("Successfully seeded tenant: %s", t.Namespace)
 	return nil
 }
 
 // EncryptTenant encrypts tenant credentials using AES-256 encryption
-func (tm *Manager) EncryptTenant(t tenant) (string, error) {
+func (tm *Manager) EncryptTenant(t TenantInfo) (string, error) {
 	// Convert tenant to TenantData string
 	data := string(t.toTenantData())
 
@@ -155,29 +407,37 @@ func (tm *Manager) EncryptTenant(t tenant) (string, error) {
 }
 
 // decryptTenant decrypts the encrypted tenant credentials
-func (tm *Manager) decryptTenant(et EncryptedTenant) (tenant, error) {
+func (tm *Manager) decryptTenant(et EncryptedTenant) (TenantInfo, error) {
 	// Use generic.DecryptAES256 to decrypt the credentials
 	decryptedData, err := generic.DecryptAES256(string(tm.secret), et.Credentials)
 	if err != nil {
-		return tenant{}, fmt.Errorf("failed to decrypt tenant data: %w", err)
+		return TenantInfo{}, fmt.Errorf("failed to decrypt tenant data: %w", err)
 	}
 
 	// Convert decrypted data back to tenant
 	t, err := data(decryptedData).toTenant(et.Namespace)
 	if err != nil {
-		return tenant{}, fmt.Errorf("error converting decrypted data to tenant: %v", err)
+		return TenantInfo{}, fmt.Errorf("error converting decrypted data to tenant: %v", err)
 	}
 
 	return t, nil
 }
 
+// SyncTenants registers the given tenants in the persisted registry and
+// creates their schemas and users. It is idempotent: tenants already
+// registered are updated, not duplicated.
 func (tm *Manager) SyncTenants(encryptedTenants *[]EncryptedTenant) error {
-	err := tm.loadTenants(encryptedTenants)
+	ctx := context.Background()
+	if err := tm.ensureRegistry(ctx); err != nil {
+		return err
+	}
+
+	tenants, err := tm.loadTenants(ctx, encryptedTenants)
 	if err != nil {
 		return fmt.Errorf("failed to load tenants: %w", err)
 	}
 
-	err = tm.seedTenants()
+	err = tm.seedTenants(ctx, tenants)
 	if err != nil {
 		return fmt.Errorf("failed to seed tenants: %w", err)
 	}
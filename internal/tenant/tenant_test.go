@@ -0,0 +1,180 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder collects the statements a dry-run session would execute.
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+func newDryRunManager(t *testing.T) (*Manager, *sqlRecorder) {
+	t.Helper()
+	rec := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 rec,
+	})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	tm, err := NewTenantManager(db, strings.Repeat("k", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tm, rec
+}
+
+func (r *sqlRecorder) count(substr string) int {
+	n := 0
+	for _, s := range r.statements {
+		if strings.Contains(s, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSyncTenantsIsIdempotent(t *testing.T) {
+	tm, rec := newDryRunManager(t)
+	creds, err := tm.EncryptTenant(TenantInfo{Username: "acme_user", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenants := []EncryptedTenant{{Namespace: "acme", Credentials: creds}}
+	for range 2 {
+		if err := tm.SyncTenants(&tenants); err != nil {
+			t.Fatalf("SyncTenants: %v", err)
+		}
+	}
+
+	if n := rec.count(`CREATE TABLE "public"."gossiper_tenants"`); n != 1 {
+		t.Errorf("registry created %d times, want 1", n)
+	}
+	if n := rec.count(`ON CONFLICT ("namespace") DO UPDATE`); n != 2 {
+		t.Errorf("%d upserts, want 2:\n%s", n, strings.Join(rec.statements, "\n"))
+	}
+	if n := rec.count(`SET "status"='active'`); n != 2 {
+		t.Errorf("%d status updates, want 2", n)
+	}
+
+	rec.statements = nil
+	if ok, err := tm.Exists(context.Background(), "acme"); !ok || err != nil {
+		t.Errorf("Exists(acme) = %v, %v; want true", ok, err)
+	}
+	if len(rec.statements) != 0 {
+		t.Errorf("Exists of an active tenant queried the database: %v", rec.statements)
+	}
+	if ok, _ := tm.Exists(context.Background(), "globex"); ok {
+		t.Error("Exists(globex) = true")
+	}
+	if rec.count(`namespace = 'globex' AND status = 'active'`) != 1 {
+		t.Errorf("Exists of an unknown tenant did not check active tenants: %v", rec.statements)
+	}
+}
+
+func TestExistsRemembersUnknownTenants(t *testing.T) {
+	tm, rec := newDryRunManager(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm.now = func() time.Time { return now }
+	ctx := context.Background()
+	lookups := func() int { return rec.count(`namespace = 'globex' AND status = 'active'`) }
+
+	for range 3 {
+		if ok, err := tm.Exists(ctx, "globex"); ok || err != nil {
+			t.Fatalf("Exists(globex) = %v, %v; want false", ok, err)
+		}
+	}
+	if n := lookups(); n != 1 {
+		t.Errorf("%d registry lookups within the negative cache TTL, want 1", n)
+	}
+
+	now = now.Add(unknownTTL)
+	if _, err := tm.Exists(ctx, "globex"); err != nil {
+		t.Fatal(err)
+	}
+	if n := lookups(); n != 2 {
+		t.Errorf("%d registry lookups after the TTL, want 2", n)
+	}
+
+	if err := tm.setStatus(ctx, "globex", StatusActive); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := tm.Exists(ctx, "globex"); !ok {
+		t.Error("Exists(globex) = false after it became active")
+	}
+}
+
+func TestExistsRechecksActiveTenants(t *testing.T) {
+	tm, rec := newDryRunManager(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm.now = func() time.Time { return now }
+	ctx := context.Background()
+	if err := tm.setStatus(ctx, "acme", StatusActive); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.statements = nil
+	if ok, _ := tm.Exists(ctx, "acme"); !ok {
+		t.Fatal("Exists(acme) = false for a cached active tenant")
+	}
+	if len(rec.statements) != 0 {
+		t.Errorf("Exists within the TTL queried the database: %v", rec.statements)
+	}
+
+	// The dry run finds no rows, as if another instance deactivated acme.
+	now = now.Add(activeTTL)
+	if ok, _ := tm.Exists(ctx, "acme"); ok {
+		t.Error("Exists(acme) = true after the TTL without rechecking the registry")
+	}
+	if n := rec.count(`namespace = 'acme' AND status = 'active'`); n != 1 {
+		t.Errorf("%d registry lookups after the TTL, want 1", n)
+	}
+}
+
+func TestTenantInfoOmitsPassword(t *testing.T) {
+	tm, _ := newDryRunManager(t)
+	creds, err := tm.EncryptTenant(TenantInfo{Username: "acme_user", Password: "pw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	info, err := tm.info(record{
+		Namespace:     "acme",
+		Credentials:   creds,
+		Status:        StatusActive,
+		SchemaVersion: 3,
+		CreatedAt:     created,
+		UpdatedAt:     created,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TenantInfo{
+		Namespace:     "acme",
+		Username:      "acme_user",
+		Status:        StatusActive,
+		SchemaVersion: 3,
+		CreatedAt:     created,
+		UpdatedAt:     created,
+	}
+	if info != want {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+}
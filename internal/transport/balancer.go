@@ -0,0 +1,394 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Balancer chooses the endpoint for each call of a transport configured
+// with WithResolver.
+type Balancer string
+
+const (
+	// RoundRobin spreads calls evenly across healthy endpoints.
+	RoundRobin Balancer = "round_robin"
+	// LeastRequest sends each call to the less busy of two random healthy
+	// endpoints.
+	LeastRequest Balancer = "least_request"
+	// TenantHash pins each tenant to one endpoint with rendezvous hashing,
+	// so a tenant only moves when its endpoint leaves the set. Calls
+	// without a tenant in the context fall back to RoundRobin.
+	TenantHash Balancer = "tenant_hash"
+)
+
+// WithBalancer selects how calls are spread across resolved endpoints.
+// The default is RoundRobin.
+func WithBalancer(b Balancer) Option {
+	return func(o *options) {
+		o.balancer = b
+	}
+}
+
+// EndpointHealthConfig controls how endpoints are ejected based on call
+// outcomes. Zero fields take the defaults noted on each field.
+type EndpointHealthConfig struct {
+	// ConsecutiveFailures ejects an endpoint after this many failed calls
+	// in a row. Default 5.
+	ConsecutiveFailures int
+	// EjectionTime is how long an ejected endpoint receives no calls.
+	// Default 30s.
+	EjectionTime time.Duration
+	// FailureCodes lists the status codes counted as endpoint failures.
+	// Default UNAVAILABLE.
+	FailureCodes []codes.Code
+}
+
+func (c EndpointHealthConfig) withDefaults() EndpointHealthConfig {
+	if c.ConsecutiveFailures <= 0 {
+		c.ConsecutiveFailures = 5
+	}
+	if c.EjectionTime <= 0 {
+		c.EjectionTime = 30 * time.Second
+	}
+	if c.FailureCodes == nil {
+		c.FailureCodes = []codes.Code{codes.Unavailable}
+	}
+	return c
+}
+
+// WithEndpointHealth tunes passive health tracking of resolved endpoints.
+func WithEndpointHealth(cfg EndpointHealthConfig) Option {
+	return func(o *options) {
+		o.endpointHealth = cfg
+	}
+}
+
+type endpoint struct {
+	addr     string
+	conn     *grpc.ClientConn
+	inflight atomic.Int64
+	retired  atomic.Bool
+	closed   atomic.Bool
+
+	closeOnce sync.Once
+	closeErr  error
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+// acquire counts a call on e. It fails once e has been removed from the
+// endpoint set, so the caller picks again.
+func (e *endpoint) acquire() bool {
+	e.inflight.Add(1)
+	if e.retired.Load() {
+		e.release()
+		return false
+	}
+	return true
+}
+
+// release ends a call counted by acquire, closing the connection of a
+// removed endpoint after its last call.
+func (e *endpoint) release() {
+	if e.inflight.Add(-1) == 0 && e.retired.Load() {
+		_ = e.close()
+	}
+}
+
+// retire takes e out of use. Its connection closes now if it is idle, or
+// when the last call in flight releases it.
+func (e *endpoint) retire() {
+	e.retired.Store(true)
+	if e.inflight.Load() == 0 {
+		_ = e.close()
+	}
+}
+
+func (e *endpoint) close() error {
+	e.closeOnce.Do(func() {
+		e.closeErr = e.conn.Close()
+		e.closed.Store(true)
+	})
+	return e.closeErr
+}
+
+func (e *endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.ejectedUntil)
+}
+
+// record updates the endpoint's health from a call outcome.
+func (e *endpoint) record(cfg EndpointHealthConfig, target string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil || !slices.Contains(cfg.FailureCodes, status.Code(err)) {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures >= cfg.ConsecutiveFailures {
+		e.failures = 0
+		e.ejectedUntil = time.Now().Add(cfg.EjectionTime)
+		slog.Warn("endpoint ejected", "target", target, "endpoint", e.addr, "for", cfg.EjectionTime)
+	}
+}
+
+// balancedConn is a grpc.ClientConnInterface over the resolved endpoints
+// of a target. The transport's breaker and policy interceptors run above
+// endpoint selection, so each retry attempt picks an endpoint afresh.
+type balancedConn struct {
+	target   string
+	balancer Balancer
+	health   EndpointHealthConfig
+	invoke   grpc.UnaryInvoker
+
+	mu        sync.RWMutex
+	closed    bool
+	endpoints []*endpoint
+	retired   []*endpoint // removed, with calls still in flight
+	next      atomic.Uint32
+}
+
+func newBalancedConn(target string, o options, interceptors []grpc.UnaryClientInterceptor) *balancedConn {
+	b := &balancedConn{target: target, balancer: o.balancer, health: o.endpointHealth.withDefaults()}
+	b.invoke = chainInvoker(interceptors, b.invokeOnce)
+	return b
+}
+
+// chainInvoker applies interceptors, first outermost, around final.
+func chainInvoker(interceptors []grpc.UnaryClientInterceptor, final grpc.UnaryInvoker) grpc.UnaryInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], final
+		final = func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return interceptor(ctx, method, req, reply, cc, next, opts...)
+		}
+	}
+	return final
+}
+
+func (b *balancedConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	return b.invoke(ctx, method, args, reply, nil, opts...)
+}
+
+func (b *balancedConn) invokeOnce(ctx context.Context, method string, req, reply any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+	ep, err := b.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = ep.conn.Invoke(ctx, method, req, reply, opts...)
+	ep.release()
+	ep.record(b.health, b.target, err)
+	return err
+}
+
+func (b *balancedConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ep, err := b.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := ep.conn.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		ep.release()
+		return nil, err
+	}
+	release := sync.OnceFunc(ep.release)
+	// A stream whose context ends is over even if it is never received
+	// from again.
+	stop := context.AfterFunc(ctx, release)
+	return &endpointStream{
+		ClientStream: cs,
+		done:         func() { stop(); release() },
+		single:       !desc.ServerStreams,
+	}, nil
+}
+
+// acquire picks the endpoint for a call and counts the call on it.
+func (b *balancedConn) acquire(ctx context.Context) (*endpoint, error) {
+	for {
+		ep := b.pick(ctx)
+		if ep == nil {
+			return nil, status.Errorf(codes.Unavailable, "no endpoints resolved for %s", b.target)
+		}
+		// A failed acquire means update removed ep after pick read the
+		// endpoint set, so the next pick sees the new set.
+		if ep.acquire() {
+			return ep, nil
+		}
+	}
+}
+
+// endpointStream releases its endpoint when the stream ends.
+type endpointStream struct {
+	grpc.ClientStream
+	done   func()
+	single bool
+}
+
+func (s *endpointStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || s.single {
+		s.done()
+	}
+	return err
+}
+
+// pick returns the endpoint for a call, or nil if none are resolved. When
+// every endpoint is ejected, all of them are considered again rather than
+// failing every call.
+func (b *balancedConn) pick(ctx context.Context) *endpoint {
+	b.mu.RLock()
+	all := b.endpoints
+	b.mu.RUnlock()
+	if len(all) == 0 {
+		return nil
+	}
+	now := time.Now()
+	candidates := make([]*endpoint, 0, len(all))
+	for _, ep := range all {
+		if ep.available(now) {
+			candidates = append(candidates, ep)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = all
+	}
+
+	switch b.balancer {
+	case LeastRequest:
+		if len(candidates) == 1 {
+			return candidates[0]
+		}
+		i := rand.IntN(len(candidates))
+		j := rand.IntN(len(candidates) - 1)
+		if j >= i {
+			j++
+		}
+		if candidates[j].inflight.Load() < candidates[i].inflight.Load() {
+			return candidates[j]
+		}
+		return candidates[i]
+	case TenantHash:
+		if tenant := observability.Tenant(ctx); tenant != "" {
+			return rendezvous(tenant, candidates)
+		}
+	}
+	return candidates[int(b.next.Add(1))%len(candidates)]
+}
+
+// rendezvous returns the endpoint with the highest hash for key.
+func rendezvous(key string, candidates []*endpoint) *endpoint {
+	var best *endpoint
+	var bestScore uint64
+	for _, ep := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(ep.addr))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = ep, score
+		}
+	}
+	return best
+}
+
+// update replaces the endpoint set with addrs, dialing new endpoints and
+// closing removed ones once the calls in flight on them have finished.
+func (b *balancedConn) update(addrs []string, dial func(addr string) (*grpc.ClientConn, error)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrTransportClosed
+	}
+	current := make(map[string]*endpoint, len(b.endpoints))
+	for _, ep := range b.endpoints {
+		current[ep.addr] = ep
+	}
+	next := make([]*endpoint, 0, len(addrs))
+	var dialed []*grpc.ClientConn
+	for _, addr := range addrs {
+		if ep, ok := current[addr]; ok {
+			next = append(next, ep)
+			delete(current, addr)
+			continue
+		}
+		conn, err := dial(addr)
+		if err != nil {
+			for _, c := range dialed {
+				_ = c.Close()
+			}
+			return err
+		}
+		dialed = append(dialed, conn)
+		next = append(next, &endpoint{addr: addr, conn: conn})
+	}
+	b.retired = slices.DeleteFunc(b.retired, func(ep *endpoint) bool { return ep.closed.Load() })
+	for _, ep := range current {
+		ep.retire()
+		if !ep.closed.Load() {
+			b.retired = append(b.retired, ep)
+		}
+	}
+	b.endpoints = next
+	return nil
+}
+
+func (b *balancedConn) snapshot() []*endpoint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.endpoints
+}
+
+// close closes every endpoint, including removed ones with calls still in
+// flight, and makes later updates fail so no connection is dialed after
+// it.
+func (b *balancedConn) close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	var errs []error
+	for _, ep := range slices.Concat(b.endpoints, b.retired) {
+		if err := ep.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	b.endpoints, b.retired = nil, nil
+	return errors.Join(errs...)
+}
+
+// refresh re-resolves every interval until ctx is done.
+func (b *balancedConn) refresh(ctx context.Context, r Resolver, interval time.Duration, dial func(addr string) (*grpc.ClientConn, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		addrs, err := resolveOnce(ctx, r)
+		if err == nil && len(addrs) == 0 {
+			continue
+		}
+		if err == nil {
+			err = b.update(addrs, dial)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("endpoint refresh failed, keeping previous endpoints", "target", b.target, "error", err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// hits counts calls per in-process endpoint name.
+type hits struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (h *hits) get(name string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.counts[name]
+}
+
+// serveEndpoints registers an in-process health server per name.
+func serveEndpoints(t *testing.T, names ...string) *hits {
+	t.Helper()
+	h := &hits{counts: map[string]int{}}
+	for _, name := range names {
+		server := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			h.mu.Lock()
+			h.counts[name]++
+			h.mu.Unlock()
+			return handler(ctx, req)
+		}))
+		healthpb.RegisterHealthServer(server, health.NewServer())
+		stop, err := ServeInProcess(name, server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			stop()
+			server.Stop()
+		})
+	}
+	return h
+}
+
+func check(ctx context.Context, t *testing.T, tr Transport) error {
+	t.Helper()
+	client, err := NewClient(tr, healthpb.NewHealthClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Call(ctx, tr, client.Check, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestBalancer_RoundRobinSpreadsCalls(t *testing.T) {
+	names := []string{"rr-a", "rr-b", "rr-c"}
+	h := serveEndpoints(t, names...)
+	tr := NewInProcessTransport("rr", WithResolver(StaticResolver(names...)))
+	defer tr.Close()
+
+	for range 9 {
+		if err := check(context.Background(), t, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range names {
+		if got := h.get(name); got != 3 {
+			t.Errorf("%s served %d calls, want 3", name, got)
+		}
+	}
+}
+
+func TestBalancer_TenantHashPinsTenants(t *testing.T) {
+	names := []string{"th-a", "th-b", "th-c"}
+	h := serveEndpoints(t, names...)
+	tr := NewInProcessTransport("th", WithResolver(StaticResolver(names...)), WithBalancer(TenantHash))
+	defer tr.Close()
+
+	ctx := observability.WithRequestData(context.Background(), "req", "acme", "")
+	for range 5 {
+		if err := check(ctx, t, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var served []string
+	for _, name := range names {
+		if n := h.get(name); n > 0 {
+			served = append(served, fmt.Sprintf("%s=%d", name, n))
+		}
+	}
+	if len(served) != 1 {
+		t.Fatalf("tenant acme was served by %v, want a single endpoint", served)
+	}
+
+	for i := range 30 {
+		ctx := observability.WithRequestData(context.Background(), "req", fmt.Sprintf("tenant-%d", i), "")
+		if err := check(ctx, t, tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	used := 0
+	for _, name := range names {
+		if h.get(name) > 0 {
+			used++
+		}
+	}
+	if used < 2 {
+		t.Errorf("30 tenants used %d endpoints, want them spread", used)
+	}
+}
+
+func TestBalancer_LeastRequestPrefersIdleEndpoint(t *testing.T) {
+	busy, idle := &endpoint{addr: "busy"}, &endpoint{addr: "idle"}
+	busy.inflight.Store(10)
+	b := &balancedConn{balancer: LeastRequest, endpoints: []*endpoint{busy, idle}}
+	for range 20 {
+		if ep := b.pick(context.Background()); ep != idle {
+			t.Fatalf("picked %s, want idle", ep.addr)
+		}
+	}
+}
+
+func TestBalancer_EjectsFailingEndpoint(t *testing.T) {
+	h := serveEndpoints(t, "ej-up")
+	policy := DefaultPolicy()
+	policy.Default.Retry.InitialBackoff = time.Millisecond
+	tr := NewInProcessTransport("ej",
+		WithResolver(StaticResolver("ej-up", "ej-down")),
+		WithPolicy(policy),
+		WithEndpointHealth(EndpointHealthConfig{ConsecutiveFailures: 1, EjectionTime: time.Minute}),
+	)
+	defer tr.Close()
+
+	for range 6 {
+		if err := check(context.Background(), t, tr); err != nil {
+			t.Fatalf("call failed despite a healthy endpoint: %v", err)
+		}
+	}
+	if got := h.get("ej-up"); got != 6 {
+		t.Errorf("healthy endpoint served %d calls, want 6", got)
+	}
+	if err := tr.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck = %v, want nil while one endpoint is healthy", err)
+	}
+}
+
+// lazyDial returns connections that never connect, recording each one.
+func lazyDial(t *testing.T, dialed *[]*grpc.ClientConn) func(string) (*grpc.ClientConn, error) {
+	return func(addr string) (*grpc.ClientConn, error) {
+		conn, err := grpc.NewClient("passthrough:///"+addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		*dialed = append(*dialed, conn)
+		return conn, nil
+	}
+}
+
+func TestBalancer_ClosesRemovedEndpointAfterInFlightCalls(t *testing.T) {
+	var dialed []*grpc.ClientConn
+	dial := lazyDial(t, &dialed)
+	b := &balancedConn{target: "rm"}
+	if err := b.update([]string{"busy", "idle"}, dial); err != nil {
+		t.Fatal(err)
+	}
+	busy, idle := b.endpoints[0], b.endpoints[1]
+	if !busy.acquire() {
+		t.Fatal("acquire failed on a live endpoint")
+	}
+
+	if err := b.update([]string{"new"}, dial); err != nil {
+		t.Fatal(err)
+	}
+	if idle.conn.GetState() != connectivity.Shutdown {
+		t.Error("idle removed endpoint was not closed")
+	}
+	if busy.conn.GetState() == connectivity.Shutdown {
+		t.Fatal("removed endpoint closed under a call in flight")
+	}
+	if busy.acquire() {
+		t.Error("acquire succeeded on a removed endpoint")
+	}
+	busy.release()
+	if busy.conn.GetState() != connectivity.Shutdown {
+		t.Error("removed endpoint not closed after its last call")
+	}
+}
+
+func TestBalancer_CloseStopsUpdates(t *testing.T) {
+	var dialed []*grpc.ClientConn
+	dial := lazyDial(t, &dialed)
+	b := &balancedConn{target: "cl"}
+	if err := b.update([]string{"a", "b"}, dial); err != nil {
+		t.Fatal(err)
+	}
+	if !b.endpoints[0].acquire() {
+		t.Fatal("acquire failed on a live endpoint")
+	}
+	if err := b.update([]string{"b"}, dial); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.update([]string{"c"}, dial); !errors.Is(err, ErrTransportClosed) {
+		t.Errorf("update after close = %v, want ErrTransportClosed", err)
+	}
+	if len(dialed) != 2 {
+		t.Errorf("dialed %d connections, want 2", len(dialed))
+	}
+	for i, conn := range dialed {
+		if conn.GetState() != connectivity.Shutdown {
+			t.Errorf("connection %d still open after close", i)
+		}
+	}
+}
+
+func TestBalancer_RefreshPicksUpNewEndpoints(t *testing.T) {
+	h := serveEndpoints(t, "rf-old", "rf-new")
+	var current atomic.Value
+	current.Store([]string{"rf-old"})
+	resolver := ResolverFunc(func(context.Context) ([]string, error) {
+		return current.Load().([]string), nil
+	})
+	tr := NewInProcessTransport("rf", WithResolver(resolver), WithResolveInterval(10*time.Millisecond))
+	defer tr.Close()
+
+	if err := check(context.Background(), t, tr); err != nil {
+		t.Fatal(err)
+	}
+	current.Store([]string{"rf-new"})
+	deadline := time.Now().Add(2 * time.Second)
+	for h.get("rf-new") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("calls never reached the re-resolved endpoint")
+		}
+		if err := check(context.Background(), t, tr); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBalancer_HangingResolveDoesNotBlockClose(t *testing.T) {
+	release := make(chan struct{})
+	resolving := make(chan struct{})
+	resolver := ResolverFunc(func(context.Context) ([]string, error) {
+		close(resolving)
+		<-release
+		return []string{"hang-1"}, nil
+	})
+	tr := NewInProcessTransport("hang", WithResolver(resolver))
+
+	created := make(chan error, 1)
+	go func() {
+		_, err := NewClient(tr, healthpb.NewHealthClient)
+		created <- err
+	}()
+	<-resolving
+
+	closed := make(chan error, 1)
+	go func() { closed <- tr.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a hanging resolver")
+	}
+	if err := tr.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck = %v", err)
+	}
+
+	close(release)
+	if err := <-created; !errors.Is(err, ErrTransportClosed) {
+		t.Errorf("NewClient after Close = %v, want ErrTransportClosed", err)
+	}
+}
+
+func TestDNSSRVResolver(t *testing.T) {
+	lookup := func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if service != "grpc" || proto != "tcp" || name != "billing.default.svc" {
+			return "", nil, fmt.Errorf("unexpected lookup _%s._%s.%s", service, proto, name)
+		}
+		return "", []*net.SRV{
+			{Target: "billing-0.billing.default.svc.", Port: 9000},
+			{Target: "billing-1.billing.default.svc.", Port: 9000},
+		}, nil
+	}
+	addrs, err := dnsSRVResolver(lookup, "grpc", "tcp", "billing.default.svc").Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"billing-0.billing.default.svc:9000", "billing-1.billing.default.svc:9000"}
+	if !slices.Equal(addrs, want) {
+		t.Errorf("addrs = %v, want %v", addrs, want)
+	}
+
+	if _, err := dnsSRVResolver(lookup, "grpc", "tcp", "missing").Resolve(context.Background()); err == nil {
+		t.Error("Resolve returned nil error for a failed lookup")
+	}
+}
+
+func TestFileResolver_ReadsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endpoints")
+	if err := os.WriteFile(path, []byte("# billing\n10.0.0.1:50051\n\n10.0.0.2:50051\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r := FileResolver(path)
+	got, err := r.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1:50051", "10.0.0.2:50051"}; !slices.Equal(got, want) {
+		t.Fatalf("Resolve = %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("10.0.0.3:50051\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	got, err = r.Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.3:50051"}; !slices.Equal(got, want) {
+		t.Fatalf("Resolve after rewrite = %v, want %v", got, want)
+	}
+}
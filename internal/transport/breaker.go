@@ -0,0 +1,290 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call through and counts failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the cool-down has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a few probe calls through to test recovery.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerConfig configures the opt-in circuit breaker. Zero fields take
+// the defaults noted on each field.
+type BreakerConfig struct {
+	// Window is the period over which the failure ratio is measured.
+	// Default 10s.
+	Window time.Duration
+	// MinRequests is the number of calls a window needs before the
+	// breaker may trip. Default 10.
+	MinRequests int
+	// FailureRatio trips the breaker when failures/requests reaches it.
+	// Default 0.5.
+	FailureRatio float64
+	// CoolDown is how long the breaker stays open before probing.
+	// Default 30s.
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of probe calls allowed, and required
+	// to succeed, before the breaker closes again. Default 1.
+	HalfOpenRequests int
+	// FailureCodes lists the status codes counted as failures. Default
+	// UNAVAILABLE, DEADLINE_EXCEEDED, RESOURCE_EXHAUSTED, INTERNAL, UNKNOWN.
+	// HTTP responses count by the code HTTPError maps them to, and failed
+	// RabbitMQ publishes as UNKNOWN unless the context ended.
+	FailureCodes []codes.Code
+	// PerMethod keeps a separate breaker per method instead of one for the
+	// whole target: per path for HTTP and per routing key for RabbitMQ.
+	PerMethod bool
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 10
+	}
+	if c.FailureRatio <= 0 {
+		c.FailureRatio = 0.5
+	}
+	if c.CoolDown <= 0 {
+		c.CoolDown = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	if c.FailureCodes == nil {
+		c.FailureCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Internal, codes.Unknown}
+	}
+	return c
+}
+
+// WithCircuitBreaker enables a circuit breaker on the transport's calls.
+func WithCircuitBreaker(cfg BreakerConfig) Option {
+	return func(o *options) {
+		cfg = cfg.withDefaults()
+		o.breaker = &cfg
+	}
+}
+
+// CircuitOpenError is returned without contacting the target while its
+// circuit breaker is open.
+type CircuitOpenError struct {
+	Target     string
+	Method     string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker open for %s%s, retry after %s", e.Target, e.Method, e.RetryAfter.Round(time.Millisecond))
+}
+
+// GRPCStatus maps the error to UNAVAILABLE so status.Code and gRPC servers
+// relaying it upstream treat it like any other unreachable dependency.
+func (e *CircuitOpenError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// breakers holds the breaker for a target, or one per method.
+type breakers struct {
+	cfg    BreakerConfig
+	target string
+
+	mu  sync.Mutex
+	all map[string]*breaker
+}
+
+func newBreakers(target string, cfg BreakerConfig) *breakers {
+	return &breakers{cfg: cfg, target: target, all: make(map[string]*breaker)}
+}
+
+func (bs *breakers) get(method string) *breaker {
+	key := ""
+	if bs.cfg.PerMethod {
+		key = method
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.all[key]
+	if !ok {
+		b = &breaker{cfg: bs.cfg, target: bs.target, key: key, windowStart: time.Now()}
+		bs.all[key] = b
+	}
+	return b
+}
+
+// interceptor short-circuits calls while the breaker is open. It runs
+// outside the retry interceptor, so one logical call counts once.
+func (bs *breakers) interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return bs.do(ctx, method, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+	}
+}
+
+// do runs call unless the breaker for method is open, and records its
+// outcome. HTTP and RabbitMQ transports call it outside their retries, like
+// the gRPC interceptor.
+func (bs *breakers) do(ctx context.Context, method string, call func() error) error {
+	b := bs.get(method)
+	gen, err := b.allow(ctx, time.Now())
+	if err != nil {
+		trace.SpanFromContext(ctx).AddEvent("circuit_breaker.rejected", trace.WithAttributes(
+			attribute.String("rpc.target", bs.target),
+			attribute.String("rpc.method", method),
+		))
+		return err
+	}
+	err = call()
+	failed := err != nil && slices.Contains(bs.cfg.FailureCodes, errorCode(err))
+	b.record(ctx, time.Now(), gen, failed)
+	return err
+}
+
+// errorCode returns the status code of err, mapping context errors that
+// carry no status to CANCELED or DEADLINE_EXCEEDED.
+func errorCode(err error) codes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return status.FromContextError(err).Code()
+}
+
+type breaker struct {
+	cfg    BreakerConfig
+	target string
+	key    string
+
+	mu          sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+// allow reports whether a call may proceed and returns the generation the
+// result must be recorded against.
+func (b *breaker) allow(ctx context.Context, now time.Time) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= b.cfg.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+	case BreakerOpen:
+		if wait := b.openedAt.Add(b.cfg.CoolDown).Sub(now); wait > 0 {
+			return 0, &CircuitOpenError{Target: b.target, Method: b.key, RetryAfter: wait}
+		}
+		b.transition(ctx, BreakerHalfOpen, now)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			return 0, &CircuitOpenError{Target: b.target, Method: b.key}
+		}
+		b.probes++
+	}
+	return b.generation, nil
+}
+
+// record counts the outcome of a call admitted under generation gen.
+// Outcomes from before the last state change are ignored.
+func (b *breaker) record(ctx context.Context, now time.Time, gen uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.generation {
+		return
+	}
+
+	switch b.state {
+	case BreakerClosed:
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+			b.transition(ctx, BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if failed {
+			b.transition(ctx, BreakerOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.cfg.HalfOpenRequests {
+			b.transition(ctx, BreakerClosed, now)
+		}
+	}
+}
+
+// transition moves to state, resets the counters and reports the change
+// through the request logger and the current span. Callers hold b.mu.
+func (b *breaker) transition(ctx context.Context, to BreakerState, now time.Time) {
+	from := b.state
+	b.state = to
+	b.generation++
+	b.windowStart, b.requests, b.failures = now, 0, 0
+	b.probes, b.successes = 0, 0
+	if to == BreakerOpen {
+		b.openedAt = now
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.target", b.target),
+		attribute.String("circuit_breaker.from", from.String()),
+		attribute.String("circuit_breaker.to", to.String()),
+	}
+	if b.key != "" {
+		attrs = append(attrs, attribute.String("rpc.method", b.key))
+	}
+	trace.SpanFromContext(ctx).AddEvent("circuit_breaker.state_change", trace.WithAttributes(attrs...))
+
+	level := slog.LevelInfo
+	if to == BreakerOpen {
+		level = slog.LevelWarn
+	}
+	observability.LoggerFromContext(ctx, nil).Log(ctx, level, "circuit breaker state changed",
+		slog.String("target", b.target),
+		slog.String("method", b.key),
+		slog.String("from", from.String()),
+		slog.String("to", to.String()),
+	)
+}
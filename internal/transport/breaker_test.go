@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func failingInvoker(code codes.Code) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if code == codes.OK {
+			return nil
+		}
+		return status.Error(code, "failed")
+	}
+}
+
+func TestBreaker_OpensAfterFailureRatio(t *testing.T) {
+	bs := newBreakers("billing:50051", BreakerConfig{MinRequests: 4, FailureRatio: 0.5, CoolDown: time.Hour}.withDefaults())
+	intercept := bs.interceptor()
+	ctx := context.Background()
+
+	_ = intercept(ctx, "/svc.S/M", nil, nil, nil, failingInvoker(codes.OK))
+	_ = intercept(ctx, "/svc.S/M", nil, nil, nil, failingInvoker(codes.OK))
+	_ = intercept(ctx, "/svc.S/M", nil, nil, nil, failingInvoker(codes.Unavailable))
+	if got := bs.get("/svc.S/M").state; got != BreakerClosed {
+		t.Fatalf("state = %s before MinRequests, want closed", got)
+	}
+	_ = intercept(ctx, "/svc.S/M", nil, nil, nil, failingInvoker(codes.Unavailable))
+	if got := bs.get("/svc.S/M").state; got != BreakerOpen {
+		t.Fatalf("state = %s, want open", got)
+	}
+
+	called := false
+	err := intercept(ctx, "/svc.S/M", nil, nil, nil, func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		called = true
+		return nil
+	})
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+	if called {
+		t.Error("expected the call to be short-circuited")
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("status code = %s, want Unavailable", status.Code(err))
+	}
+}
+
+func TestBreaker_IgnoresNonFailureCodes(t *testing.T) {
+	bs := newBreakers("billing:50051", BreakerConfig{MinRequests: 2}.withDefaults())
+	intercept := bs.interceptor()
+	for range 5 {
+		_ = intercept(context.Background(), "/svc.S/M", nil, nil, nil, failingInvoker(codes.NotFound))
+	}
+	if got := bs.get("/svc.S/M").state; got != BreakerClosed {
+		t.Fatalf("state = %s, want closed", got)
+	}
+}
+
+func TestBreaker_HalfOpenProbeClosesOrReopens(t *testing.T) {
+	b := &breaker{cfg: BreakerConfig{MinRequests: 1, CoolDown: time.Minute}.withDefaults(), target: "t", windowStart: time.Now()}
+	ctx := context.Background()
+	now := time.Now()
+
+	gen, _ := b.allow(ctx, now)
+	b.record(ctx, now, gen, true)
+	if b.state != BreakerOpen {
+		t.Fatalf("state = %s, want open", b.state)
+	}
+
+	// After the cool-down one probe is admitted, a second is not.
+	later := now.Add(time.Minute)
+	gen, err := b.allow(ctx, later)
+	if err != nil || b.state != BreakerHalfOpen {
+		t.Fatalf("expected a half-open probe, got state %s err %v", b.state, err)
+	}
+	if _, err := b.allow(ctx, later); err == nil {
+		t.Fatal("expected a second concurrent probe to be rejected")
+	}
+
+	b.record(ctx, later, gen, true)
+	if b.state != BreakerOpen {
+		t.Fatalf("failed probe: state = %s, want open", b.state)
+	}
+
+	evenLater := later.Add(time.Minute)
+	gen, _ = b.allow(ctx, evenLater)
+	b.record(ctx, evenLater, gen, false)
+	if b.state != BreakerClosed {
+		t.Fatalf("successful probe: state = %s, want closed", b.state)
+	}
+}
+
+func TestBreaker_PerMethodIsolation(t *testing.T) {
+	bs := newBreakers("t", BreakerConfig{MinRequests: 1, PerMethod: true, CoolDown: time.Hour}.withDefaults())
+	intercept := bs.interceptor()
+	_ = intercept(context.Background(), "/svc.S/Broken", nil, nil, nil, failingInvoker(codes.Unavailable))
+
+	if err := intercept(context.Background(), "/svc.S/Healthy", nil, nil, nil, failingInvoker(codes.OK)); err != nil {
+		t.Fatalf("expected other methods to keep working, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryMethod is the shape of every unary method on a protoc-generated gRPC
+// client, e.g. pb.SomeServiceClient.GetItems.
+type UnaryMethod[Req, Resp any] func(ctx context.Context, req Req, opts ...grpc.CallOption) (Resp, error)
+
+// Call invokes method through t with the same context middleware, default
+// deadline and logging as Send, but with request and response types
+// checked at compile time:
+//
+//	resp, err := transport.Call(ctx, t, client.GetItems, &pb.GetItemsRequest{})
+func Call[Req, Resp any](ctx context.Context, t Transport, method UnaryMethod[Req, Resp], req Req, opts ...grpc.CallOption) (Resp, error) {
+	var resp Resp
+	err := invoke(ctx, t, methodName(method), func(ctx context.Context) error {
+		var err error
+		resp, err = method(ctx, req, opts...)
+		return err
+	})
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return resp, nil
+}
+
+// invoker is implemented by the transports of this package: Invoke runs
+// call with the transport's context middleware, default deadline and error
+// logging.
+type invoker interface {
+	Invoke(ctx context.Context, method string, call func(ctx context.Context) error) error
+}
+
+// invoke runs call through t's Invoke when t has one. Other Transport
+// implementations, such as test doubles, only get the context middleware.
+func invoke(ctx context.Context, t Transport, method string, call func(ctx context.Context) error) error {
+	if inv, ok := t.(invoker); ok {
+		return inv.Invoke(ctx, method, call)
+	}
+	if sendContextMiddleware != nil {
+		ctx = sendContextMiddleware(ctx)
+	}
+	return call(ctx)
+}
+
+// NewClient creates a typed client from a protoc-generated constructor such
+// as pb.NewSomeServiceClient, so callers never need a type assertion.
+func NewClient[T any](t Transport, constructor func(cc grpc.ClientConnInterface) T) (T, error) {
+	client, err := t.CreateClient(constructor)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	typed, ok := client.(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("client constructor returned %T", client)
+	}
+	return typed, nil
+}
+
+// methodName derives a readable name ("SomeServiceClient.GetItems") from a
+// method value for logging.
+func methodName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	name := strings.TrimSuffix(f.Name(), "-fm")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.NewReplacer("(", "", ")", "", "*", "").Replace(name)
+}
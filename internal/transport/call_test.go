@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+type itemsRequest struct{ ID string }
+type itemsResponse struct{ Name string }
+
+type fakeItemsClient struct {
+	sawDeadline bool
+	sawValue    any
+}
+
+func (c *fakeItemsClient) GetItem(ctx context.Context, in *itemsRequest, _ ...grpc.CallOption) (*itemsResponse, error) {
+	_, c.sawDeadline = ctx.Deadline()
+	c.sawValue = ctx.Value(middlewareKey{})
+	if in.ID == "" {
+		return nil, errors.New("missing id")
+	}
+	return &itemsResponse{Name: "item-" + in.ID}, nil
+}
+
+type middlewareKey struct{}
+
+func TestCall_TypedResponseWithDeadlineAndMiddleware(t *testing.T) {
+	RegisterSendContextMiddleware(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, middlewareKey{}, "enriched")
+	})
+	defer RegisterSendContextMiddleware(nil)
+
+	client := &fakeItemsClient{}
+	tr := NewGRPCTransport("localhost:0")
+
+	resp, err := Call(context.Background(), tr, client.GetItem, &itemsRequest{ID: "42"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Name != "item-42" {
+		t.Errorf("resp.Name = %q, want item-42", resp.Name)
+	}
+	if !client.sawDeadline {
+		t.Error("expected the default deadline to be applied")
+	}
+	if client.sawValue != "enriched" {
+		t.Errorf("expected context middleware to run, got %v", client.sawValue)
+	}
+}
+
+func TestCall_ReturnsZeroResponseOnError(t *testing.T) {
+	client := &fakeItemsClient{}
+	tr := NewGRPCTransport("localhost:0")
+
+	resp, err := Call(context.Background(), tr, client.GetItem, &itemsRequest{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if resp != nil {
+		t.Errorf("expected nil response on error, got %v", resp)
+	}
+}
+
+// minimalTransport implements only the Transport interface, as external
+// implementations and test doubles do.
+type minimalTransport struct{}
+
+func (minimalTransport) CreateClient(any) (any, error) { return nil, nil }
+func (minimalTransport) Send(context.Context, any, string, any) (any, error) {
+	return nil, nil
+}
+func (minimalTransport) Close() error { return nil }
+
+func TestCall_MinimalTransportGetsMiddleware(t *testing.T) {
+	RegisterSendContextMiddleware(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, middlewareKey{}, "enriched")
+	})
+	defer RegisterSendContextMiddleware(nil)
+
+	client := &fakeItemsClient{}
+	resp, err := Call(context.Background(), minimalTransport{}, client.GetItem, &itemsRequest{ID: "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "item-7" {
+		t.Errorf("resp = %+v, want item-7", resp)
+	}
+	if client.sawValue != "enriched" {
+		t.Errorf("context middleware not applied: %v", client.sawValue)
+	}
+}
+
+func TestMethodName(t *testing.T) {
+	client := &fakeItemsClient{}
+	if got := methodName(UnaryMethod[*itemsRequest, *itemsResponse](client.GetItem)); got != "fakeItemsClient.GetItem" {
+		t.Errorf("methodName = %q, want fakeItemsClient.GetItem", got)
+	}
+}
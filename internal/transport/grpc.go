@@ -3,18 +3,20 @@ package transport
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-// defaultCallTimeout is applied to Send calls that arrive without a deadline.
-const defaultCallTimeout = 10 * time.Second
-
 // sendContextMiddleware is an optional hook called on every outgoing gRPC Send.
 // Register it once at startup via RegisterSendContextMiddleware to inject
 // x-request-id, W3C traceparent, and any other cross-cutting metadata.
@@ -27,57 +29,275 @@ func RegisterSendContextMiddleware(fn func(ctx context.Context) context.Context)
 	sendContextMiddleware = fn
 }
 
-// retryServiceConfig enables transparent retries on transient failures.
-const retryServiceConfig = `{
-	"methodConfig": [{
-		"name": [{"service": ""}],
-		"retryPolicy": {
-			"maxAttempts": 3,
-			"initialBackoff": "0.1s",
-			"maxBackoff": "1s",
-			"backoffMultiplier": 2.0,
-			"retryableStatusCodes": ["UNAVAILABLE", "RESOURCE_EXHAUSTED"]
-		}
-	}]
-}`
+// ErrTransportClosed is returned by transport methods called after Close.
+var ErrTransportClosed = errors.New("transport is closed")
 
-// GRPCTransport handles client-side gRPC transport.
+// GRPCTransport handles client-side gRPC transport. It dials lazily and
+// reuses its connection (or small pool of connections, see WithPoolSize)
+// for every client it creates, until Close is called.
 type GRPCTransport struct {
-	address string
+	address  string
+	opts     options
+	policy   Policy
+	breakers *breakers
+
+	mu     sync.Mutex
+	conns  []*grpc.ClientConn
+	closed bool
+	next   atomic.Uint32
+
+	// balanced replaces conns when the transport uses a resolver.
+	balanced    *balancedConn
+	stopRefresh context.CancelFunc
+}
+
+func NewGRPCTransport(address string, opts ...Option) *GRPCTransport {
+	o := newOptions(opts)
+	policy := DefaultPolicy()
+	if o.policy != nil {
+		policy = *o.policy
+	}
+	g := &GRPCTransport{address: address, opts: o, policy: policy}
+	if o.breaker != nil {
+		g.breakers = newBreakers(address, *o.breaker)
+	}
+	return g
 }
 
-func NewGRPCTransport(address string) *GRPCTransport {
-	return &GRPCTransport{address: address}
+// interceptors returns the transport's unary interceptors. The breaker
+// wraps the policy so a call and its retries count once.
+func (g *GRPCTransport) interceptors() []grpc.UnaryClientInterceptor {
+	var interceptors []grpc.UnaryClientInterceptor
+	if g.breakers != nil {
+		interceptors = append(interceptors, g.breakers.interceptor())
+	}
+	return append(interceptors, policyInterceptor(g.policy))
 }
 
-// CreateClient creates a gRPC client using the passed constructor.
-// The underlying connection includes OTel trace propagation, retry policy,
-// and a stats handler for observability.
-func (g *GRPCTransport) CreateClient(clientConstructor any) (any, error) {
-	conn, err := grpc.NewClient(
-		g.address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-		grpc.WithDefaultServiceConfig(retryServiceConfig),
+// dial creates a connection to addr with the transport's credentials,
+// dialer and stats handler, plus opts.
+func (g *GRPCTransport) dial(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if g.opts.tlsConfig != nil {
+		creds = credentials.NewTLS(g.opts.tlsConfig)
+	}
+	target := addr
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
-	)
+	}
+	if g.opts.contextDialer != nil {
+		// passthrough hands the address to the dialer unresolved.
+		target = "passthrough:///" + addr
+		dialOpts = append(dialOpts, grpc.WithContextDialer(g.opts.contextDialer))
+	}
+	conn, err := grpc.NewClient(target, append(dialOpts, opts...)...)
 	if err != nil {
 		return nil, errors.New("failed to connect to gRPC server: " + err.Error())
 	}
+	return conn, nil
+}
+
+// connect returns the transport's connections, dialing them on first use.
+func (g *GRPCTransport) connect() ([]*grpc.ClientConn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, ErrTransportClosed
+	}
+	if len(g.conns) > 0 {
+		return g.conns, nil
+	}
 
+	size := max(g.opts.poolSize, 1)
+	conns := make([]*grpc.ClientConn, 0, size)
+	for range size {
+		conn, err := g.dial(g.address, grpc.WithChainUnaryInterceptor(g.interceptors()...))
+		if err != nil {
+			for _, c := range conns {
+				_ = c.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	g.conns = conns
+	return g.conns, nil
+}
+
+// balance returns the balanced connection of a transport configured with
+// WithResolver, resolving the endpoints and starting the refresh loop on
+// first use. The resolver runs outside g.mu, bounded by resolveTimeout, so
+// a hanging lookup does not block Close or HealthCheck.
+func (g *GRPCTransport) balance() (*balancedConn, error) {
+	g.mu.Lock()
+	b, closed := g.balanced, g.closed
+	g.mu.Unlock()
+	if closed {
+		return nil, ErrTransportClosed
+	}
+	if b != nil {
+		return b, nil
+	}
+
+	addrs, err := resolveOnce(context.Background(), g.opts.resolver)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("resolver returned no endpoints")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", g.address, err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil, ErrTransportClosed
+	}
+	if g.balanced != nil {
+		// A concurrent call installed its result first.
+		return g.balanced, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b = newBalancedConn(g.address, g.opts, g.interceptors())
+	dial := func(addr string) (*grpc.ClientConn, error) { return g.dial(addr) }
+	if err := b.update(addrs, dial); err != nil {
+		cancel()
+		return nil, err
+	}
+	interval := g.opts.resolveInterval
+	if interval <= 0 {
+		interval = DefaultResolveInterval
+	}
+	go b.refresh(ctx, g.opts.resolver, interval, dial)
+	g.balanced, g.stopRefresh = b, cancel
+	return b, nil
+}
+
+// clientConn returns the connection handed to client constructors, and a
+// single *grpc.ClientConn for constructors that need one (nil when the
+// transport balances across resolved endpoints).
+func (g *GRPCTransport) clientConn() (grpc.ClientConnInterface, *grpc.ClientConn, error) {
+	if g.opts.resolver != nil {
+		b, err := g.balance()
+		return b, nil, err
+	}
+	conns, err := g.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	legacy := conns[int(g.next.Add(1))%len(conns)]
+	if len(conns) > 1 {
+		return connPool{conns: conns, next: &g.next}, legacy, nil
+	}
+	return conns[0], legacy, nil
+}
+
+// CreateClient creates a gRPC client using the passed constructor.
+// The underlying connection includes OTel trace propagation, the
+// transport's timeout/retry/hedging policy, and a stats handler for
+// observability. It uses TLS when the transport was created with WithTLS
+// and plaintext otherwise. Clients share the transport's connection; with
+// WithPoolSize, calls are spread round-robin across the pool.
+func (g *GRPCTransport) CreateClient(clientConstructor any) (any, error) {
 	constructorValue := reflect.ValueOf(clientConstructor)
 	if constructorValue.Kind() != reflect.Func {
 		return nil, errors.New("clientConstructor must be a function")
 	}
+	constructorType := constructorValue.Type()
+	if constructorType.NumIn() != 1 {
+		return nil, errors.New("clientConstructor must take exactly one connection argument")
+	}
 
-	clientValues := constructorValue.Call([]reflect.Value{reflect.ValueOf(conn)})
+	cc, legacy, err := g.clientConn()
+	if err != nil {
+		return nil, err
+	}
+
+	arg := reflect.ValueOf(cc)
+	if !arg.Type().AssignableTo(constructorType.In(0)) {
+		// Older constructors take *grpc.ClientConn; give them one pool member.
+		if legacy == nil {
+			return nil, errors.New("clientConstructor must accept grpc.ClientConnInterface when the transport uses a resolver")
+		}
+		arg = reflect.ValueOf(legacy)
+	}
+
+	clientValues := constructorValue.Call([]reflect.Value{arg})
 	if len(clientValues) > 0 {
 		return clientValues[0].Interface(), nil
 	}
 	return nil, errors.New("failed to create gRPC client")
 }
 
+// Close closes every connection owned by the transport. Clients created
+// from it stop working; further CreateClient calls return
+// ErrTransportClosed. Close is idempotent.
+func (g *GRPCTransport) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+	var errs []error
+	for _, conn := range g.conns {
+		if err := conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	g.conns = nil
+	if g.balanced != nil {
+		g.stopRefresh()
+		errs = append(errs, g.balanced.close())
+		g.balanced = nil
+	}
+	return errors.Join(errs...)
+}
+
+// connPool spreads calls across several connections to the same target.
+type connPool struct {
+	conns []*grpc.ClientConn
+	next  *atomic.Uint32
+}
+
+func (p connPool) pick() *grpc.ClientConn {
+	return p.conns[int(p.next.Add(1))%len(p.conns)]
+}
+
+func (p connPool) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	return p.pick().Invoke(ctx, method, args, reply, opts...)
+}
+
+func (p connPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return p.pick().NewStream(ctx, desc, method, opts...)
+}
+
+// HealthCheck reports an error if any connection created by this transport
+// is in TRANSIENT_FAILURE, i.e. the target is currently unreachable. With a
+// resolver it only reports an error when no endpoint is healthy.
+func (g *GRPCTransport) HealthCheck(_ context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.balanced != nil {
+		now := time.Now()
+		for _, ep := range g.balanced.snapshot() {
+			if ep.available(now) && ep.conn.GetState() != connectivity.TransientFailure {
+				return nil
+			}
+		}
+		return fmt.Errorf("no healthy endpoint for %s", g.address)
+	}
+	for _, conn := range g.conns {
+		if conn.GetState() == connectivity.TransientFailure {
+			return fmt.Errorf("connection to %s is in %s", g.address, connectivity.TransientFailure)
+		}
+	}
+	return nil
+}
+
 // Send invokes a gRPC method by name via reflection.
-// If the context has no deadline, a default timeout is applied.
+// If the context has no deadline, the policy's timeout is applied.
+// Prefer the typed Call, which is checked at compile time.
 func (g *GRPCTransport) Send(ctx context.Context, client any, serviceMethod string, request any) (any, error) {
 	clientValue := reflect.ValueOf(client)
 	method := clientValue.MethodByName(serviceMethod)
@@ -85,26 +305,47 @@ func (g *GRPCTransport) Send(ctx context.Context, client any, serviceMethod stri
 		return nil, errors.New("invalid service method: " + serviceMethod)
 	}
 
-	if sendContextMiddleware != nil {
-		ctx = sendContextMiddleware(ctx)
-	}
-
-	if _, ok := ctx.Deadline(); !ok {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, defaultCallTimeout)
-		defer cancel()
-	}
-
 	reqValue := reflect.ValueOf(request)
 	if !reqValue.IsValid() {
 		return nil, errors.New("invalid request for method: " + serviceMethod)
 	}
 
-	returnValues := method.Call([]reflect.Value{reflect.ValueOf(ctx), reqValue})
-	if len(returnValues) > 1 && returnValues[1].Interface() != nil {
-		err := returnValues[1].Interface().(error)
-		slog.Error("gRPC call failed", "method", serviceMethod, "target", g.address, "error", err)
+	var resp any
+	err := g.Invoke(ctx, serviceMethod, func(ctx context.Context) error {
+		returnValues := method.Call([]reflect.Value{reflect.ValueOf(ctx), reqValue})
+		if len(returnValues) > 1 && returnValues[1].Interface() != nil {
+			return returnValues[1].Interface().(error)
+		}
+		resp = returnValues[0].Interface()
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	return returnValues[0].Interface(), nil
+	return resp, nil
+}
+
+// Invoke runs call with the shared outgoing-call behaviour: the registered
+// context middleware, the policy's deadline when ctx has none, and error
+// logging tagged with method and target. Per-method timeouts, retries and
+// hedging are applied by the connection's policy interceptor.
+func (g *GRPCTransport) Invoke(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	if sendContextMiddleware != nil {
+		ctx = sendContextMiddleware(ctx)
+	}
+
+	ctx, cancel := g.policy.withDefaultDeadline(ctx)
+	defer cancel()
+
+	if err := call(ctx); err != nil {
+		slog.Error("gRPC call failed", "method", method, "target", g.address, "error", err)
+		return err
+	}
+	return nil
+}
+
+// StreamContext runs the context middleware for a stream opened on a
+// client of this transport. See ServerStream.
+func (g *GRPCTransport) StreamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return streamContext(ctx)
 }
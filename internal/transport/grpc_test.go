@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+type connClient struct{ cc grpc.ClientConnInterface }
+
+func newConnClient(cc grpc.ClientConnInterface) *connClient { return &connClient{cc: cc} }
+
+func TestGRPCTransport_ReusesConnectionAcrossClients(t *testing.T) {
+	tr := NewGRPCTransport("localhost:0")
+	defer tr.Close()
+
+	a, err := NewClient(tr, newConnClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewClient(tr, newConnClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.cc != b.cc {
+		t.Error("expected clients to share one connection")
+	}
+	if len(tr.conns) != 1 {
+		t.Errorf("expected 1 connection, got %d", len(tr.conns))
+	}
+}
+
+func TestGRPCTransport_PoolSpreadsAcrossConnections(t *testing.T) {
+	tr := NewGRPCTransport("localhost:0", WithPoolSize(3))
+	defer tr.Close()
+
+	c, err := NewClient(tr, newConnClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, ok := c.cc.(connPool)
+	if !ok {
+		t.Fatalf("expected a pooled connection, got %T", c.cc)
+	}
+	seen := map[*grpc.ClientConn]bool{}
+	for range 3 {
+		seen[pool.pick()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected round-robin over 3 connections, saw %d", len(seen))
+	}
+}
+
+func TestGRPCTransport_LegacyConstructorGetsClientConn(t *testing.T) {
+	tr := NewGRPCTransport("localhost:0", WithPoolSize(2))
+	defer tr.Close()
+
+	client, err := tr.CreateClient(func(cc *grpc.ClientConn) *grpc.ClientConn { return cc })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(*grpc.ClientConn); !ok {
+		t.Fatalf("expected *grpc.ClientConn, got %T", client)
+	}
+}
+
+func TestGRPCTransport_CloseRejectsNewClients(t *testing.T) {
+	tr := NewGRPCTransport("localhost:0")
+	if _, err := NewClient(tr, newConnClient); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("second close should be a no-op, got %v", err)
+	}
+	if _, err := NewClient(tr, newConnClient); !errors.Is(err, ErrTransportClosed) {
+		t.Fatalf("expected ErrTransportClosed, got %v", err)
+	}
+}
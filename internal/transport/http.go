@@ -0,0 +1,330 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// HTTPError is returned for responses with a 4xx or 5xx status. Its
+// GRPCStatus maps the HTTP status onto a gRPC code, so retry policies and
+// status.Code treat HTTP and gRPC failures alike.
+type HTTPError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *HTTPError) GRPCStatus() *status.Status {
+	return status.New(httpStatusCode(e.StatusCode), e.Error())
+}
+
+func httpStatusCode(code int) grpccodes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return grpccodes.InvalidArgument
+	case http.StatusUnauthorized:
+		return grpccodes.Unauthenticated
+	case http.StatusForbidden:
+		return grpccodes.PermissionDenied
+	case http.StatusNotFound:
+		return grpccodes.NotFound
+	case http.StatusConflict:
+		return grpccodes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return grpccodes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return grpccodes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpccodes.Unavailable
+	case http.StatusGatewayTimeout:
+		return grpccodes.DeadlineExceeded
+	}
+	if code >= 500 {
+		return grpccodes.Internal
+	}
+	return grpccodes.Unknown
+}
+
+// HTTPTransport calls JSON HTTP endpoints, such as those served by a REST
+// server, with the same context middleware, deadlines and retry policy as
+// the gRPC transport. Hedging is not applied to HTTP calls.
+type HTTPTransport struct {
+	baseURL  string
+	policy   Policy
+	client   *http.Client
+	breakers *breakers
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewHTTPTransport returns a transport for the service at baseURL, e.g.
+// "http://billing:8080".
+func NewHTTPTransport(baseURL string, opts ...Option) *HTTPTransport {
+	o := newOptions(opts)
+	policy := DefaultPolicy()
+	if o.policy != nil {
+		policy = *o.policy
+	}
+	rt := http.DefaultTransport.(*http.Transport).Clone()
+	if o.tlsConfig != nil {
+		rt.TLSClientConfig = o.tlsConfig
+	}
+	h := &HTTPTransport{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		policy:  policy,
+		client:  &http.Client{Transport: rt},
+	}
+	if o.breaker != nil {
+		h.breakers = newBreakers(h.baseURL, *o.breaker)
+	}
+	return h
+}
+
+// CreateClient returns the transport's *http.Client, or passes it to
+// clientConstructor when that is a func(*http.Client) T and returns T.
+func (h *HTTPTransport) CreateClient(clientConstructor any) (any, error) {
+	if err := h.checkOpen(); err != nil {
+		return nil, err
+	}
+	if clientConstructor == nil {
+		return h.client, nil
+	}
+	constructorValue := reflect.ValueOf(clientConstructor)
+	constructorType := constructorValue.Type()
+	if constructorType.Kind() != reflect.Func || constructorType.NumIn() != 1 ||
+		constructorType.In(0) != reflect.TypeOf(h.client) || constructorType.NumOut() == 0 {
+		return nil, errors.New("HTTP client constructor must be a func(*http.Client) T")
+	}
+	return constructorValue.Call([]reflect.Value{reflect.ValueOf(h.client)})[0].Interface(), nil
+}
+
+func (h *HTTPTransport) checkOpen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrTransportClosed
+	}
+	return nil
+}
+
+// Send calls serviceMethod, written as "VERB /path" or just "/path" for a
+// POST, with request encoded as JSON ([]byte is sent as-is, nil sends no
+// body). It returns the response body as json.RawMessage. The client
+// argument is ignored and may be nil. Per-method policies are keyed by the
+// path without its leading slash. Only idempotent verbs are retried unless
+// the method's RetryPolicy sets NonIdempotent. With WithCircuitBreaker, the
+// breaker sees each call once, after its retries.
+func (h *HTTPTransport) Send(ctx context.Context, _ any, serviceMethod string, request any) (any, error) {
+	verb, path, ok := strings.Cut(serviceMethod, " ")
+	if !ok {
+		verb, path = http.MethodPost, serviceMethod
+	}
+	body, err := encodeJSON(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp json.RawMessage
+	err = h.Invoke(ctx, serviceMethod, func(ctx context.Context) error {
+		if err := h.checkOpen(); err != nil {
+			return err
+		}
+		cp := h.policy.resolve(ctx, path)
+		ctx, cancel := cp.withTimeout(ctx)
+		defer cancel()
+		rp := cp.Retry
+		if rp != nil && !rp.NonIdempotent && !idempotent(verb) {
+			rp = nil
+		}
+		send := func() error {
+			return retryDo(ctx, rp, func() error {
+				var err error
+				resp, err = h.do(ctx, verb, path, body)
+				return err
+			})
+		}
+		if h.breakers != nil {
+			return h.breakers.do(ctx, path, send)
+		}
+		return send()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// idempotent reports whether repeating a request with verb has the same
+// effect as sending it once.
+func idempotent(verb string) bool {
+	switch verb {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func encodeJSON(request any) ([]byte, error) {
+	switch v := request.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("encode %T: %w", request, err)
+	}
+	return body, nil
+}
+
+// do performs one attempt. The span is named after the verb alone, since
+// paths carry IDs, and url.full leaves out the query, which may carry
+// tokens or personal data.
+func (h *HTTPTransport) do(ctx context.Context, verb, path string, body []byte) (json.RawMessage, error) {
+	url := h.baseURL + path
+	fullURL, _, _ := strings.Cut(url, "?")
+	ctx, span := otel.Tracer("gossiper/transport").Start(ctx, verb,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", verb),
+			attribute.String("url.full", fullURL),
+		),
+	)
+	defer span.End()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, verb, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	setOutgoingHeaders(ctx, req.Header)
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return nil, status.Error(grpccodes.Unavailable, err.Error())
+	}
+	defer res.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, status.Error(grpccodes.Unavailable, err.Error())
+	}
+	if res.StatusCode >= 400 {
+		err := &HTTPError{StatusCode: res.StatusCode, Method: verb, URL: url, Body: data}
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// setOutgoingHeaders copies the outgoing gRPC metadata set by the context
+// middleware into HTTP headers, then adds x-request-id and the trace
+// context of the current span.
+func setOutgoingHeaders(ctx context.Context, header http.Header) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	for key, values := range md {
+		if strings.HasSuffix(key, "-bin") {
+			continue
+		}
+		for _, v := range values {
+			header.Add(key, v)
+		}
+	}
+	if id := observability.RequestID(ctx); id != "" {
+		header.Set("X-Request-Id", id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Invoke runs call with the registered context middleware, the policy's
+// deadline when ctx has none, and error logging tagged with method and
+// target.
+func (h *HTTPTransport) Invoke(ctx context.Context, method string, call func(ctx context.Context) error) error {
+	if sendContextMiddleware != nil {
+		ctx = sendContextMiddleware(ctx)
+	}
+
+	ctx, cancel := h.policy.withDefaultDeadline(ctx)
+	defer cancel()
+
+	if err := call(ctx); err != nil {
+		slog.Error("HTTP call failed", "method", method, "target", h.baseURL, "error", err)
+		return err
+	}
+	return nil
+}
+
+// Close releases idle connections; further calls return
+// ErrTransportClosed. Close is idempotent.
+func (h *HTTPTransport) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		h.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+// SendJSON calls t.Send and decodes the JSON response into Resp. Use it
+// with HTTP transports.
+func SendJSON[Resp any](ctx context.Context, t Transport, method string, request any) (Resp, error) {
+	var resp Resp
+	out, err := t.Send(ctx, nil, method, request)
+	if err != nil {
+		return resp, err
+	}
+	raw, ok := out.(json.RawMessage)
+	if !ok {
+		return resp, fmt.Errorf("transport %T does not return JSON", t)
+	}
+	if len(raw) == 0 {
+		return resp, nil
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return resp, fmt.Errorf("decode %s response: %w", method, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,234 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func fastRetryPolicy() Policy {
+	p := DefaultPolicy()
+	p.Default.Retry.InitialBackoff = time.Millisecond
+	p.Default.Retry.MaxBackoff = time.Millisecond
+	return p
+}
+
+func TestHTTPTransport_SendsJSONWithContextHeaders(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	var gotHeader http.Header
+	var gotBody map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/orders/1" {
+			t.Errorf("request = %s %s, want PUT /orders/1", r.Method, r.URL.Path)
+		}
+		gotHeader = r.Header.Clone()
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Write([]byte(`{"status":"paid"}`))
+	}))
+	defer srv.Close()
+
+	tr := NewFactory().CreateTransport(HTTP, srv.URL+"/")
+	defer tr.Close()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0xab},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = observability.WithRequestData(ctx, "req-9", "", "")
+
+	resp, err := SendJSON[map[string]string](ctx, tr, "PUT /orders/1", map[string]string{"state": "paid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp["status"] != "paid" {
+		t.Errorf("response = %v, want status paid", resp)
+	}
+	if gotBody["state"] != "paid" {
+		t.Errorf("request body = %v, want state paid", gotBody)
+	}
+	if got := gotHeader.Get("X-Request-Id"); got != "req-9" {
+		t.Errorf("X-Request-Id = %q, want req-9", got)
+	}
+	if tp := gotHeader.Get("Traceparent"); len(tp) < 35 || tp[3:35] != sc.TraceID().String() {
+		t.Errorf("traceparent = %q, want trace id %s", tp, sc.TraceID())
+	}
+}
+
+func TestHTTPTransport_SpanHasLowCardinalityNameAndNoQuery(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport(srv.URL)
+	if _, err := tr.Send(context.Background(), nil, "GET /users/42?token=secret", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if spans[0].Name != "GET" {
+		t.Errorf("span name = %q, want GET", spans[0].Name)
+	}
+	for _, a := range spans[0].Attributes {
+		if a.Key == "url.full" && a.Value.AsString() != srv.URL+"/users/42" {
+			t.Errorf("url.full = %q, want it without the query", a.Value.AsString())
+		}
+	}
+}
+
+func TestHTTPTransport_RetriesUnavailable(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`"ok"`))
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport(srv.URL, WithPolicy(fastRetryPolicy()))
+	resp, err := SendJSON[string](context.Background(), tr, "GET /ping", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "ok" || calls.Load() != 3 {
+		t.Errorf("resp = %q after %d calls, want ok after 3", resp, calls.Load())
+	}
+}
+
+func TestHTTPTransport_CircuitBreakerCountsCallsAfterRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policy := fastRetryPolicy()
+	policy.Default.Retry.MaxAttempts = 2
+	tr := NewHTTPTransport(srv.URL, WithPolicy(policy),
+		WithCircuitBreaker(BreakerConfig{MinRequests: 2, CoolDown: time.Hour}))
+	for range 2 {
+		if _, err := tr.Send(context.Background(), nil, "GET /ping", nil); status.Code(err) != codes.Unavailable {
+			t.Fatalf("err = %v, want Unavailable", err)
+		}
+	}
+	if got := calls.Load(); got != 4 {
+		t.Fatalf("server got %d calls, want 2 calls of 2 attempts", got)
+	}
+
+	_, err := tr.Send(context.Background(), nil, "GET /ping", nil)
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) {
+		t.Fatalf("err = %v, want CircuitOpenError", err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("server got %d calls, want none while the breaker is open", got)
+	}
+}
+
+func TestHTTPTransport_RetriesPostOnlyWhenOptedIn(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	policy := fastRetryPolicy()
+	optIn := *policy.Default.Retry
+	optIn.NonIdempotent = true
+	policy.Methods = map[string]CallPolicy{"payments/lookup": {Retry: &optIn}}
+	tr := NewHTTPTransport(srv.URL, WithPolicy(policy))
+
+	for _, tt := range []struct {
+		method string
+		want   int32
+	}{
+		{"/orders", 1},
+		{"PATCH /orders/1", 1},
+		{"DELETE /orders/1", 3},
+		{"POST /payments/lookup", 3},
+	} {
+		calls.Store(0)
+		if _, err := tr.Send(context.Background(), nil, tt.method, nil); status.Code(err) != codes.Unavailable {
+			t.Fatalf("%s: err = %v, want Unavailable", tt.method, err)
+		}
+		if got := calls.Load(); got != tt.want {
+			t.Errorf("%s: server called %d times, want %d", tt.method, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPTransport_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport(srv.URL, WithPolicy(fastRetryPolicy()))
+	_, err := tr.Send(context.Background(), nil, "/orders", map[string]int{"qty": -1})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want *HTTPError with 400", err)
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("status code = %v, want InvalidArgument", status.Code(err))
+	}
+	if calls.Load() != 1 {
+		t.Errorf("server called %d times, want 1", calls.Load())
+	}
+}
+
+func TestHTTPTransport_AppliesPolicyTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	tr := NewHTTPTransport(srv.URL, WithPolicy(Policy{Default: CallPolicy{Timeout: 50 * time.Millisecond}}))
+	_, err := tr.Send(context.Background(), nil, "GET /slow", nil)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestHTTPTransport_CloseRejectsCalls(t *testing.T) {
+	tr := NewHTTPTransport("http://localhost:0")
+	if _, err := tr.CreateClient(func(c *http.Client) *http.Client { return c }); err != nil {
+		t.Fatal(err)
+	}
+	tr.Close()
+	if _, err := tr.Send(context.Background(), nil, "/x", nil); !errors.Is(err, ErrTransportClosed) {
+		t.Fatalf("err = %v, want ErrTransportClosed", err)
+	}
+}
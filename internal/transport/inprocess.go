@@ -0,0 +1,23 @@
+package transport
+
+import (
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/inprocess"
+	"google.golang.org/grpc"
+)
+
+// NewInProcessTransport returns a gRPC transport that dials the server
+// registered under name with ServeInProcess instead of the network. name
+// may also be the host:port a GRPC transport would use, which matches a
+// server registered under that port. Policies, breakers and middleware
+// behave exactly as on a networked transport.
+func NewInProcessTransport(name string, opts ...Option) *GRPCTransport {
+	t := NewGRPCTransport(name, opts...)
+	t.opts.contextDialer = inprocess.Dial
+	return t
+}
+
+// ServeInProcess makes server reachable by InProcess transports under
+// name. stop unregisters it; it does not stop server.
+func ServeInProcess(name string, server *grpc.Server) (stop func(), err error) {
+	return inprocess.Serve(name, server)
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func serveHealth(t *testing.T, name string) {
+	t.Helper()
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	stop, err := ServeInProcess(name, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stop()
+		server.Stop()
+	})
+}
+
+func TestInProcessTransport_CallsRegisteredServer(t *testing.T) {
+	serveHealth(t, "inprocess-health")
+
+	tr := NewFactory().CreateTransport(InProcess, "inprocess-health")
+	defer tr.Close()
+	client, err := NewClient(tr, healthpb.NewHealthClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := Call(context.Background(), tr, client.Check, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", resp.GetStatus())
+	}
+}
+
+func TestInProcessTransport_MatchesHostPortByPort(t *testing.T) {
+	serveHealth(t, "50099")
+
+	tr := NewInProcessTransport("billing:50099")
+	defer tr.Close()
+	client, err := NewClient(tr, healthpb.NewHealthClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Call(context.Background(), tr, client.Check, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInProcessTransport_UnknownNameFails(t *testing.T) {
+	tr := NewInProcessTransport("nobody-here", WithPolicy(Policy{}))
+	defer tr.Close()
+	client, err := NewClient(tr, healthpb.NewHealthClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Call(context.Background(), tr, client.Check, &healthpb.HealthCheckRequest{}); err == nil {
+		t.Fatal("expected an error for an unregistered in-process server")
+	}
+}
+
+func TestServeInProcess_RejectsDuplicateName(t *testing.T) {
+	serveHealth(t, "inprocess-dup")
+	if _, err := ServeInProcess("inprocess-dup", grpc.NewServer()); err == nil {
+		t.Fatal("expected an error registering the same name twice")
+	}
+}
@@ -0,0 +1,287 @@
+package transport
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultCallTimeout is applied to calls that arrive without a deadline
+// when the policy does not set one.
+const defaultCallTimeout = 10 * time.Second
+
+// RetryPolicy retries a failed call with exponential backoff and jitter.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 1 disables retries.
+	MaxAttempts       int
+	InitialBackoff    time.Duration
+	MaxBackoff        time.Duration
+	BackoffMultiplier float64
+	// RetryableCodes lists the status codes worth retrying.
+	RetryableCodes []codes.Code
+	// NonIdempotent lets the HTTP transport retry POST and PATCH calls,
+	// which it otherwise sends once. Set it in the per-method policy of
+	// endpoints that are safe to repeat. gRPC calls ignore it.
+	NonIdempotent bool
+}
+
+// HedgingPolicy sends up to MaxAttempts copies of a call, starting a new
+// one every HedgingDelay until one succeeds. Only use it for idempotent
+// methods. Requests and responses must be protobuf messages.
+type HedgingPolicy struct {
+	MaxAttempts  int
+	HedgingDelay time.Duration
+	// NonFatalCodes lists codes after which the remaining hedges keep
+	// going; any other failure is returned immediately.
+	NonFatalCodes []codes.Code
+}
+
+// CallPolicy controls deadline, retry and hedging for a call. Zero fields
+// inherit from the enclosing policy level. Retry and Hedging are mutually
+// exclusive; Hedging wins when both are set.
+type CallPolicy struct {
+	// Timeout is applied when the caller's context has no deadline.
+	Timeout time.Duration
+	Retry   *RetryPolicy
+	Hedging *HedgingPolicy
+}
+
+// Policy is the complete call policy of a transport: defaults for every
+// method plus per-method overrides.
+type Policy struct {
+	Default CallPolicy
+	// Methods overrides Default for specific methods, keyed by
+	// "package.Service/Method" or by "package.Service" for a whole service.
+	Methods map[string]CallPolicy
+}
+
+// DefaultPolicy returns the policy transports use unless configured
+// otherwise: a 10s deadline and up to 3 attempts on UNAVAILABLE and
+// RESOURCE_EXHAUSTED.
+func DefaultPolicy() Policy {
+	return Policy{
+		Default: CallPolicy{
+			Timeout: defaultCallTimeout,
+			Retry: &RetryPolicy{
+				MaxAttempts:       3,
+				InitialBackoff:    100 * time.Millisecond,
+				MaxBackoff:        time.Second,
+				BackoffMultiplier: 2.0,
+				RetryableCodes:    []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+			},
+		},
+	}
+}
+
+// WithPolicy replaces the transport's DefaultPolicy.
+func WithPolicy(p Policy) Option {
+	return func(o *options) {
+		o.policy = &p
+	}
+}
+
+type callPolicyKey struct{}
+
+// defaultedDeadlineKey marks contexts whose deadline was set by Invoke
+// rather than the caller, so the per-method timeout may tighten it.
+type defaultedDeadlineKey struct{}
+
+// WithCallPolicy overrides the transport and per-method policy for calls
+// made with the returned context. Zero fields keep the configured values.
+func WithCallPolicy(ctx context.Context, p CallPolicy) context.Context {
+	return context.WithValue(ctx, callPolicyKey{}, p)
+}
+
+// WithCallTimeout is shorthand for WithCallPolicy with only Timeout set.
+func WithCallTimeout(ctx context.Context, d time.Duration) context.Context {
+	p, _ := ctx.Value(callPolicyKey{}).(CallPolicy)
+	p.Timeout = d
+	return WithCallPolicy(ctx, p)
+}
+
+// merge returns p with the non-zero fields of override applied.
+func (p CallPolicy) merge(override CallPolicy) CallPolicy {
+	if override.Timeout > 0 {
+		p.Timeout = override.Timeout
+	}
+	if override.Retry != nil {
+		p.Retry = override.Retry
+		p.Hedging = nil
+	}
+	if override.Hedging != nil {
+		p.Hedging = override.Hedging
+	}
+	return p
+}
+
+// resolve returns the effective policy for fullMethod ("/pkg.Svc/Method")
+// and the call context.
+func (p Policy) resolve(ctx context.Context, fullMethod string) CallPolicy {
+	resolved := p.Default
+	name := strings.TrimPrefix(fullMethod, "/")
+	if service, _, ok := strings.Cut(name, "/"); ok {
+		if sp, ok := p.Methods[service]; ok {
+			resolved = resolved.merge(sp)
+		}
+	}
+	if mp, ok := p.Methods[name]; ok {
+		resolved = resolved.merge(mp)
+	}
+	if cp, ok := ctx.Value(callPolicyKey{}).(CallPolicy); ok {
+		resolved = resolved.merge(cp)
+	}
+	if resolved.Timeout <= 0 {
+		resolved.Timeout = defaultCallTimeout
+	}
+	return resolved
+}
+
+// outerTimeout is the deadline Invoke applies before the method is known:
+// the call override if any, otherwise the longest configured timeout so
+// the interceptor can narrow it per method.
+func (p Policy) outerTimeout(ctx context.Context) time.Duration {
+	if cp, ok := ctx.Value(callPolicyKey{}).(CallPolicy); ok && cp.Timeout > 0 {
+		return cp.Timeout
+	}
+	longest := p.Default.Timeout
+	for _, mp := range p.Methods {
+		longest = max(longest, mp.Timeout)
+	}
+	if longest <= 0 {
+		longest = defaultCallTimeout
+	}
+	return longest
+}
+
+// withDefaultDeadline applies the policy's outer timeout when ctx has no
+// deadline of its own.
+func (p Policy) withDefaultDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	ctx = context.WithValue(ctx, defaultedDeadlineKey{}, true)
+	return context.WithTimeout(ctx, p.outerTimeout(ctx))
+}
+
+// withTimeout applies cp.Timeout unless the caller set its own deadline.
+func (cp CallPolicy) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	_, hasDeadline := ctx.Deadline()
+	if defaulted, _ := ctx.Value(defaultedDeadlineKey{}).(bool); defaulted || !hasDeadline {
+		return context.WithTimeout(ctx, cp.Timeout)
+	}
+	return ctx, func() {}
+}
+
+// policyInterceptor enforces per-method timeouts, retries and hedging.
+func policyInterceptor(p Policy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		cp := p.resolve(ctx, method)
+		ctx, cancel := cp.withTimeout(ctx)
+		defer cancel()
+
+		if cp.Hedging != nil && cp.Hedging.MaxAttempts > 1 {
+			if _, ok := reply.(proto.Message); ok {
+				return hedge(ctx, method, req, reply, cc, invoker, *cp.Hedging, opts)
+			}
+		}
+		return retry(ctx, method, req, reply, cc, invoker, cp.Retry, opts)
+	}
+}
+
+func retry(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, rp *RetryPolicy, opts []grpc.CallOption) error {
+	return retryDo(ctx, rp, func() error {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	})
+}
+
+// retryDo runs attempt until it succeeds, fails with a code rp does not
+// retry, or runs out of attempts.
+func retryDo(ctx context.Context, rp *RetryPolicy, attempt func() error) error {
+	attempts := 1
+	var backoff time.Duration
+	if rp != nil {
+		attempts = max(rp.MaxAttempts, 1)
+		backoff = rp.InitialBackoff
+	}
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || n >= attempts || !slices.Contains(rp.RetryableCodes, status.Code(err)) {
+			return err
+		}
+		// Full jitter keeps a fleet of clients from retrying in lockstep.
+		wait := time.Duration(rand.Int64N(int64(backoff) + 1))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff = time.Duration(float64(backoff) * max(rp.BackoffMultiplier, 1))
+		if rp.MaxBackoff > 0 {
+			backoff = min(backoff, rp.MaxBackoff)
+		}
+	}
+}
+
+func hedge(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, hp HedgingPolicy, opts []grpc.CallOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		reply proto.Message
+		err   error
+	}
+	results := make(chan result, hp.MaxAttempts)
+	launched := 0
+	launch := func() {
+		launched++
+		r := proto.Clone(reply.(proto.Message))
+		go func() {
+			results <- result{reply: r, err: invoker(ctx, method, req, r, cc, opts...)}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(hp.HedgingDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	finished := 0
+	for {
+		select {
+		case <-timer.C:
+			if launched < hp.MaxAttempts {
+				launch()
+				timer.Reset(hp.HedgingDelay)
+			}
+		case res := <-results:
+			finished++
+			if res.err == nil {
+				proto.Reset(reply.(proto.Message))
+				proto.Merge(reply.(proto.Message), res.reply)
+				return nil
+			}
+			lastErr = res.err
+			if !slices.Contains(hp.NonFatalCodes, status.Code(res.err)) {
+				return res.err
+			}
+			if launched < hp.MaxAttempts {
+				launch()
+				timer.Reset(hp.HedgingDelay)
+			} else if finished == launched {
+				return lastErr
+			}
+		case <-ctx.Done():
+			if lastErr != nil {
+				return lastErr
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
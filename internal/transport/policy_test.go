@@ -0,0 +1,121 @@
+package transport
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestPolicy_ResolvePrecedence(t *testing.T) {
+	p := DefaultPolicy()
+	p.Methods = map[string]CallPolicy{
+		"billing.Billing":        {Timeout: 20 * time.Second},
+		"billing.Billing/Export": {Timeout: time.Minute},
+	}
+
+	if got := p.resolve(context.Background(), "/users.Users/Get").Timeout; got != defaultCallTimeout {
+		t.Errorf("default timeout = %s, want %s", got, defaultCallTimeout)
+	}
+	if got := p.resolve(context.Background(), "/billing.Billing/Charge").Timeout; got != 20*time.Second {
+		t.Errorf("service timeout = %s, want 20s", got)
+	}
+	if got := p.resolve(context.Background(), "/billing.Billing/Export").Timeout; got != time.Minute {
+		t.Errorf("method timeout = %s, want 1m", got)
+	}
+	ctx := WithCallTimeout(context.Background(), 3*time.Second)
+	if got := p.resolve(ctx, "/billing.Billing/Export").Timeout; got != 3*time.Second {
+		t.Errorf("call override timeout = %s, want 3s", got)
+	}
+	if got := p.outerTimeout(context.Background()); got != time.Minute {
+		t.Errorf("outer timeout = %s, want the longest configured (1m)", got)
+	}
+}
+
+func TestPolicyInterceptor_RetriesRetryableCodes(t *testing.T) {
+	p := Policy{Default: CallPolicy{Retry: &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		RetryableCodes: []codes.Code{codes.Unavailable},
+	}}}
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if calls.Add(1) < 3 {
+			return status.Error(codes.Unavailable, "down")
+		}
+		return nil
+	}
+
+	err := policyInterceptor(p)(context.Background(), "/svc.S/M", nil, nil, nil, invoker)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("attempts = %d, want 3", calls.Load())
+	}
+}
+
+func TestPolicyInterceptor_DoesNotRetryOtherCodes(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls.Add(1)
+		return status.Error(codes.InvalidArgument, "bad")
+	}
+
+	err := policyInterceptor(DefaultPolicy())(context.Background(), "/svc.S/M", nil, nil, nil, invoker)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("attempts = %d, want 1", calls.Load())
+	}
+}
+
+func TestPolicyInterceptor_CallOverrideDisablesRetry(t *testing.T) {
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		calls.Add(1)
+		return status.Error(codes.Unavailable, "down")
+	}
+
+	ctx := WithCallPolicy(context.Background(), CallPolicy{Retry: &RetryPolicy{MaxAttempts: 1}})
+	_ = policyInterceptor(DefaultPolicy())(ctx, "/svc.S/M", nil, nil, nil, invoker)
+	if calls.Load() != 1 {
+		t.Errorf("attempts = %d, want 1", calls.Load())
+	}
+}
+
+func TestPolicyInterceptor_HedgingReturnsFirstSuccess(t *testing.T) {
+	p := Policy{Default: CallPolicy{Hedging: &HedgingPolicy{
+		MaxAttempts:  3,
+		HedgingDelay: 10 * time.Millisecond,
+	}}}
+	var calls atomic.Int32
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		n := calls.Add(1)
+		if n == 1 {
+			// The first attempt hangs until the hedge wins and cancels it.
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		reply.(*wrapperspb.StringValue).Value = "hedged"
+		return nil
+	}
+
+	reply := &wrapperspb.StringValue{}
+	err := policyInterceptor(p)(context.Background(), "/svc.S/M", nil, reply, nil, invoker)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reply.Value != "hedged" {
+		t.Errorf("reply = %q, want hedged", reply.Value)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("attempts = %d, want 2", calls.Load())
+	}
+}
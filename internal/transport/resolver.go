@@ -0,0 +1,131 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultResolveInterval is how often a transport re-resolves its
+// endpoints unless overridden with WithResolveInterval.
+const DefaultResolveInterval = 30 * time.Second
+
+// resolveTimeout bounds a single Resolve call.
+const resolveTimeout = 10 * time.Second
+
+// resolveOnce calls r with ctx bounded by resolveTimeout.
+func resolveOnce(ctx context.Context, r Resolver) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	return r.Resolve(ctx)
+}
+
+// Resolver discovers the endpoint addresses ("host:port") of a target.
+// Transports call Resolve when they first connect and then periodically,
+// keeping the last good result when a refresh fails.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// ResolverFunc adapts a function to Resolver.
+type ResolverFunc func(ctx context.Context) ([]string, error)
+
+func (f ResolverFunc) Resolve(ctx context.Context) ([]string, error) { return f(ctx) }
+
+// StaticResolver always resolves to addrs.
+func StaticResolver(addrs ...string) Resolver {
+	return ResolverFunc(func(context.Context) ([]string, error) {
+		return addrs, nil
+	})
+}
+
+// DNSSRVResolver resolves the SRV records _service._proto.name, as
+// published for example by Kubernetes headless services.
+func DNSSRVResolver(service, proto, name string) Resolver {
+	return dnsSRVResolver(net.DefaultResolver.LookupSRV, service, proto, name)
+}
+
+// lookupSRVFunc matches net.Resolver.LookupSRV.
+type lookupSRVFunc func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+func dnsSRVResolver(lookup lookupSRVFunc, service, proto, name string) Resolver {
+	return ResolverFunc(func(ctx context.Context) ([]string, error) {
+		_, records, err := lookup(ctx, service, proto, name)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, 0, len(records))
+		for _, r := range records {
+			addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+		}
+		return addrs, nil
+	})
+}
+
+// FileResolver reads one address per line from path. Blank lines and lines
+// starting with # are ignored. The file is re-read only when its
+// modification time changes.
+func FileResolver(path string) Resolver {
+	return &fileResolver{path: path}
+}
+
+type fileResolver struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	addrs   []string
+}
+
+func (f *fileResolver) Resolve(context.Context) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if f.addrs != nil && info.ModTime().Equal(f.modTime) {
+		return f.addrs, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", f.path, err)
+	}
+	f.modTime, f.addrs = info.ModTime(), addrs
+	return addrs, nil
+}
+
+// WithResolver makes a gRPC transport discover its endpoints with r and
+// balance calls across them instead of dialing its address directly. The
+// address is then only used to name the target in logs and breakers.
+func WithResolver(r Resolver) Option {
+	return func(o *options) {
+		o.resolver = r
+	}
+}
+
+// WithResolveInterval sets how often the resolver is polled.
+func WithResolveInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.resolveInterval = d
+	}
+}
@@ -0,0 +1,235 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	neturl "net/url"
+	"sync"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/rmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithExchange sets the exchange an RMQ transport publishes to. The
+// default exchange ("") routes by queue name.
+func WithExchange(name string) Option {
+	return func(o *options) {
+		o.exchange = name
+	}
+}
+
+// WithDialer replaces the AMQP dialer of an RMQ transport, e.g. with a
+// fake broker in tests.
+func WithDialer(d rmq.Dialer) Option {
+	return func(o *options) {
+		o.dialer = d
+	}
+}
+
+// RMQTransport publishes messages to RabbitMQ. It dials lazily, keeps one
+// channel in publisher confirm mode for all publishes, reopens the channel
+// after a channel error and redials after the broker closes the
+// connection.
+type RMQTransport struct {
+	url    string
+	opts   options
+	policy Policy
+	dial   rmq.Dialer
+
+	breakers *breakers
+
+	mu     sync.Mutex
+	conn   rmq.Connection
+	ch     rmq.Channel
+	closed bool
+}
+
+func NewRMQTransport(url string, opts ...Option) *RMQTransport {
+	o := newOptions(opts)
+	policy := DefaultPolicy()
+	if o.policy != nil {
+		policy = *o.policy
+	}
+	dial := rmq.Dial
+	if o.dialer != nil {
+		dial = o.dialer
+	}
+	r := &RMQTransport{url: url, opts: o, policy: policy, dial: dial}
+	if o.breaker != nil {
+		r.breakers = newBreakers(brokerHost(url), *o.breaker)
+	}
+	return r
+}
+
+// brokerHost returns the host of an AMQP URL, so breaker logs and errors
+// never carry its credentials.
+func brokerHost(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "rmq"
+	}
+	return u.Host
+}
+
+// channel returns the publishing channel, dialing on first use or after
+// the connection was lost, and reopening it on the open connection after
+// the broker closed it for a channel error.
+func (r *RMQTransport) channel() (rmq.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil, ErrTransportClosed
+	}
+	connOpen := r.conn != nil && !r.conn.IsClosed()
+	if connOpen && r.ch != nil && !r.ch.IsClosed() {
+		return r.ch, nil
+	}
+	if r.ch != nil {
+		_ = r.ch.Close()
+		r.ch = nil
+	}
+	if !connOpen {
+		conn, err := r.dial(r.url)
+		if err != nil {
+			return nil, err
+		}
+		r.conn = conn
+	}
+	ch, err := r.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("rmq: open channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("rmq: enable publisher confirms: %w", err)
+	}
+	r.ch = ch
+	return ch, nil
+}
+
+// CreateClient is not supported: RabbitMQ has no generated clients.
+// Publish with Send instead.
+func (r *RMQTransport) CreateClient(_ any) (any, error) {
+	return nil, errors.New("rmq transport has no clients, publish with Send")
+}
+
+// Send publishes request to the transport's exchange with routingKey and
+// waits for the broker to confirm it, so a publish the broker drops or
+// nacks fails instead of being lost. []byte payloads are sent as-is, proto
+// messages as protobuf and anything else as JSON. The trace context and
+// request ID travel in the headers. The client argument is ignored and may
+// be nil; the result is always nil. With WithCircuitBreaker, publishes fail
+// fast while the broker keeps failing them.
+func (r *RMQTransport) Send(ctx context.Context, _ any, routingKey string, request any) (any, error) {
+	msg, err := encodeMessage(request)
+	if err != nil {
+		return nil, err
+	}
+	err = r.Invoke(ctx, routingKey, func(ctx context.Context) error {
+		if r.breakers != nil {
+			return r.breakers.do(ctx, routingKey, func() error {
+				return r.publish(ctx, routingKey, msg)
+			})
+		}
+		return r.publish(ctx, routingKey, msg)
+	})
+	return nil, err
+}
+
+// publish publishes msg on the transport's channel and waits for the
+// broker's confirm.
+func (r *RMQTransport) publish(ctx context.Context, routingKey string, msg amqp.Publishing) error {
+	ch, err := r.channel()
+	if err != nil {
+		return err
+	}
+	msg.Headers = amqp.Table{}
+	rmq.Inject(ctx, msg.Headers)
+	if id := observability.RequestID(ctx); id != "" {
+		msg.Headers[rmq.HeaderRequestID] = id
+	}
+	confirmation, err := ch.PublishConfirmed(ctx, r.opts.exchange, routingKey, false, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("rmq: broker did not confirm publish to %q", routingKey)
+	}
+	return nil
+}
+
+func encodeMessage(request any) (amqp.Publishing, error) {
+	msg := amqp.Publishing{DeliveryMode: amqp.Persistent}
+	var err error
+	switch v := request.(type) {
+	case []byte:
+		msg.ContentType, msg.Body = "application/octet-stream", v
+	case proto.Message:
+		msg.ContentType = "application/protobuf"
+		msg.Body, err = proto.Marshal(v)
+	default:
+		msg.ContentType = "application/json"
+		msg.Body, err = json.Marshal(v)
+	}
+	if err != nil {
+		return msg, fmt.Errorf("rmq: encode %T: %w", request, err)
+	}
+	return msg, nil
+}
+
+// Invoke runs call with the registered context middleware, the policy's
+// deadline when ctx has none, and error logging tagged with the routing
+// key and target.
+func (r *RMQTransport) Invoke(ctx context.Context, routingKey string, call func(ctx context.Context) error) error {
+	if sendContextMiddleware != nil {
+		ctx = sendContextMiddleware(ctx)
+	}
+
+	ctx, cancel := r.policy.withDefaultDeadline(ctx)
+	defer cancel()
+
+	if err := call(ctx); err != nil {
+		slog.Error("RabbitMQ publish failed", "routing_key", routingKey, "exchange", r.opts.exchange, "error", err)
+		return err
+	}
+	return nil
+}
+
+// HealthCheck reports an error once a dialed connection has been closed
+// by the broker. A transport that has not published yet is healthy.
+func (r *RMQTransport) HealthCheck(_ context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn != nil && r.conn.IsClosed() {
+		return errors.New("rmq: connection to broker is closed")
+	}
+	return nil
+}
+
+// Close closes the channel and connection. Close is idempotent.
+func (r *RMQTransport) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	var errs []error
+	if r.ch != nil {
+		errs = append(errs, r.ch.Close())
+	}
+	if r.conn != nil && !r.conn.IsClosed() {
+		errs = append(errs, r.conn.Close())
+	}
+	r.conn, r.ch = nil, nil
+	return errors.Join(errs...)
+}
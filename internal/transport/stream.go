@@ -0,0 +1,146 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// ServerStreamMethod is the shape of a server-streaming method on a
+// protoc-generated gRPC client, e.g. pb.ExportClient.ExportItems.
+type ServerStreamMethod[Req, Resp any] func(ctx context.Context, req Req, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Resp], error)
+
+// ClientStreamMethod is the shape of a client-streaming method.
+type ClientStreamMethod[Req, Resp any] func(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Req, Resp], error)
+
+// BidiStreamMethod is the shape of a bidirectional streaming method.
+type BidiStreamMethod[Req, Resp any] func(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Req, Resp], error)
+
+// streamContext runs the registered context middleware for a stream and
+// applies a timeout only when one was set explicitly with WithCallPolicy or
+// WithCallTimeout: streams are often long-lived, so the policy's default
+// deadline does not apply.
+func streamContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if sendContextMiddleware != nil {
+		ctx = sendContextMiddleware(ctx)
+	}
+	if cp, ok := ctx.Value(callPolicyKey{}).(CallPolicy); ok && cp.Timeout > 0 {
+		if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+			return context.WithTimeout(ctx, cp.Timeout)
+		}
+	}
+	return context.WithCancel(ctx)
+}
+
+// streamContexter is implemented by transports that prepare stream
+// contexts themselves: StreamContext runs the context middleware and
+// returns a cancel to call once the stream is done.
+type streamContexter interface {
+	StreamContext(ctx context.Context) (context.Context, context.CancelFunc)
+}
+
+// openStreamContext prepares ctx for a stream through t's StreamContext
+// when it has one, and with streamContext otherwise.
+func openStreamContext(ctx context.Context, t Transport) (context.Context, context.CancelFunc) {
+	if sc, ok := t.(streamContexter); ok {
+		return sc.StreamContext(ctx)
+	}
+	return streamContext(ctx)
+}
+
+// streamDone returns a function that releases the stream's context and
+// logs a failure, once.
+func streamDone(method string, cancel context.CancelFunc) func(error) {
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			cancel()
+			if err != nil && !errors.Is(err, io.EOF) {
+				slog.Error("gRPC stream failed", "method", method, "error", err)
+			}
+		})
+	}
+}
+
+// ServerStream opens a server stream through t with the transport's
+// context middleware. The stream's context is released once Recv returns
+// an error, including io.EOF, so read until then or cancel ctx.
+//
+//	stream, err := transport.ServerStream(ctx, t, client.ExportItems, &pb.ExportRequest{})
+func ServerStream[Req, Resp any](ctx context.Context, t Transport, method ServerStreamMethod[Req, Resp], req Req, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Resp], error) {
+	ctx, cancel := openStreamContext(ctx, t)
+	done := streamDone(methodName(method), cancel)
+	stream, err := method(ctx, req, opts...)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	return &serverStreamClient[Resp]{ServerStreamingClient: stream, done: done}, nil
+}
+
+// ClientStream opens a client stream through t. The stream's context is
+// released by CloseAndRecv.
+func ClientStream[Req, Resp any](ctx context.Context, t Transport, method ClientStreamMethod[Req, Resp], opts ...grpc.CallOption) (grpc.ClientStreamingClient[Req, Resp], error) {
+	ctx, cancel := openStreamContext(ctx, t)
+	done := streamDone(methodName(method), cancel)
+	stream, err := method(ctx, opts...)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	return &clientStreamClient[Req, Resp]{ClientStreamingClient: stream, done: done}, nil
+}
+
+// BidiStream opens a bidirectional stream through t. The stream's context
+// is released once Recv returns an error, including io.EOF.
+func BidiStream[Req, Resp any](ctx context.Context, t Transport, method BidiStreamMethod[Req, Resp], opts ...grpc.CallOption) (grpc.BidiStreamingClient[Req, Resp], error) {
+	ctx, cancel := openStreamContext(ctx, t)
+	done := streamDone(methodName(method), cancel)
+	stream, err := method(ctx, opts...)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	return &bidiStreamClient[Req, Resp]{BidiStreamingClient: stream, done: done}, nil
+}
+
+type serverStreamClient[Resp any] struct {
+	grpc.ServerStreamingClient[Resp]
+	done func(error)
+}
+
+func (s *serverStreamClient[Resp]) Recv() (*Resp, error) {
+	m, err := s.ServerStreamingClient.Recv()
+	if err != nil {
+		s.done(err)
+	}
+	return m, err
+}
+
+type clientStreamClient[Req, Resp any] struct {
+	grpc.ClientStreamingClient[Req, Resp]
+	done func(error)
+}
+
+func (s *clientStreamClient[Req, Resp]) CloseAndRecv() (*Resp, error) {
+	m, err := s.ClientStreamingClient.CloseAndRecv()
+	s.done(err)
+	return m, err
+}
+
+type bidiStreamClient[Req, Resp any] struct {
+	grpc.BidiStreamingClient[Req, Resp]
+	done func(error)
+}
+
+func (s *bidiStreamClient[Req, Resp]) Recv() (*Resp, error) {
+	m, err := s.BidiStreamingClient.Recv()
+	if err != nil {
+		s.done(err)
+	}
+	return m, err
+}
@@ -0,0 +1,191 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/observability"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+)
+
+// serveStreaming registers an in-process server with the health and
+// reflection services, recording the request ID every stream sees.
+func serveStreaming(t *testing.T, name string) <-chan string {
+	t.Helper()
+	seen := make(chan string, 8)
+	server := grpc.NewServer(grpc.ChainStreamInterceptor(
+		observability.GRPCStreamServerInterceptor(slog.Default(), noop.NewTracerProvider().Tracer("test")),
+		func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			seen <- observability.RequestID(ss.Context())
+			return handler(srv, ss)
+		},
+	))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	stop, err := ServeInProcess(name, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		stop()
+		server.Stop()
+	})
+	return seen
+}
+
+func withOutgoingMetadataMiddleware(t *testing.T) {
+	RegisterSendContextMiddleware(observability.WithOutgoingMetadata)
+	t.Cleanup(func() { RegisterSendContextMiddleware(nil) })
+}
+
+func TestServerStream_PropagatesRequestID(t *testing.T) {
+	withOutgoingMetadataMiddleware(t)
+	seen := serveStreaming(t, "stream-server")
+	tr := NewInProcessTransport("stream-server")
+	defer tr.Close()
+	client, err := NewClient(tr, healthpb.NewHealthClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(observability.WithRequestData(context.Background(), "req-stream", "", ""))
+	defer cancel()
+	stream, err := ServerStream(ctx, tr, client.Watch, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status = %v, want SERVING", resp.GetStatus())
+	}
+	if got := <-seen; got != "req-stream" {
+		t.Errorf("server saw request id %q, want req-stream", got)
+	}
+}
+
+func TestBidiStream_RoundTrips(t *testing.T) {
+	withOutgoingMetadataMiddleware(t)
+	seen := serveStreaming(t, "stream-bidi")
+	tr := NewInProcessTransport("stream-bidi")
+	defer tr.Close()
+	client, err := NewClient(tr, reflectionpb.NewServerReflectionClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := observability.WithRequestData(context.Background(), "req-bidi", "", "")
+	stream, err := BidiStream(ctx, tr, client.ServerReflectionInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.GetListServicesResponse().GetService()) == 0 {
+		t.Error("expected the reflection service to list services")
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("Recv after CloseSend = %v, want io.EOF", err)
+	}
+	if got := <-seen; got != "req-bidi" {
+		t.Errorf("server saw request id %q, want req-bidi", got)
+	}
+}
+
+// fakeClientStream stands in for a generated client-streaming client.
+type fakeClientStream struct {
+	grpc.ClientStreamingClient[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse]
+	ctx  context.Context
+	sent int
+}
+
+func (f *fakeClientStream) Send(*healthpb.HealthCheckRequest) error {
+	f.sent++
+	return nil
+}
+
+func (f *fakeClientStream) CloseAndRecv() (*healthpb.HealthCheckResponse, error) {
+	return &healthpb.HealthCheckResponse{}, nil
+}
+
+func TestClientStream_ReleasesContextOnCloseAndRecv(t *testing.T) {
+	tr := NewInProcessTransport("unused")
+	defer tr.Close()
+	fake := &fakeClientStream{}
+	open := func(ctx context.Context, _ ...grpc.CallOption) (grpc.ClientStreamingClient[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse], error) {
+		fake.ctx = ctx
+		return fake, nil
+	}
+
+	stream, err := ClientStream(context.Background(), tr, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := stream.Send(&healthpb.HealthCheckRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.ctx.Err() != nil {
+		t.Fatal("stream context released before CloseAndRecv")
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.sent != 3 || fake.ctx.Err() == nil {
+		t.Errorf("sent %d, ctx err %v; want 3 sends and a released context", fake.sent, fake.ctx.Err())
+	}
+}
+
+// eofStream is a server stream that ends immediately.
+type eofStream struct {
+	grpc.ClientStream
+}
+
+func (eofStream) Recv() (*itemsResponse, error) { return nil, io.EOF }
+
+func TestServerStream_MinimalTransportGetsMiddleware(t *testing.T) {
+	RegisterSendContextMiddleware(func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, middlewareKey{}, "enriched")
+	})
+	defer RegisterSendContextMiddleware(nil)
+
+	var streamCtx context.Context
+	method := func(ctx context.Context, _ *itemsRequest, _ ...grpc.CallOption) (grpc.ServerStreamingClient[itemsResponse], error) {
+		streamCtx = ctx
+		return eofStream{}, nil
+	}
+	stream, err := ServerStream(context.Background(), minimalTransport{}, method, &itemsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := streamCtx.Value(middlewareKey{}); got != "enriched" {
+		t.Errorf("context middleware not applied: %v", got)
+	}
+	if _, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Recv = %v, want io.EOF", err)
+	}
+	if streamCtx.Err() == nil {
+		t.Error("stream context not released after io.EOF")
+	}
+}
@@ -1,28 +1,94 @@
 package transport
 
-import "context"
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/pieceowater-dev/lotof.lib.gossiper/v2/internal/rmq"
+)
 
 type Transport interface {
 	CreateClient(clientConstructor any) (any, error)
 	Send(ctx context.Context, client any, serviceMethod string, request any) (any, error)
+	// Close releases every connection owned by the transport.
+	Close() error
 }
 
 type Type string
 
 const (
 	GRPC Type = "grpc"
+	RMQ  Type = "rmq"
+	// HTTP calls JSON endpoints; the address is a base URL.
+	HTTP Type = "http"
+	// InProcess dials a gRPC server registered in the same process with
+	// ServeInProcess, over memory instead of the network.
+	InProcess Type = "inprocess"
 )
 
+// Option configures a transport created by Factory.CreateTransport.
+type Option func(*options)
+
+type options struct {
+	tlsConfig *tls.Config
+	poolSize  int
+	policy    *Policy
+	breaker   *BreakerConfig
+	exchange  string
+	dialer    rmq.Dialer
+	// contextDialer replaces the network dialer of gRPC connections.
+	contextDialer func(ctx context.Context, address string) (net.Conn, error)
+
+	resolver        Resolver
+	resolveInterval time.Duration
+	balancer        Balancer
+	endpointHealth  EndpointHealthConfig
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTLS secures outgoing connections with cfg instead of plaintext.
+// Build cfg with tlsconfig.ClientConfig for mTLS and certificate reload.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithPoolSize makes the transport keep n connections to its target and
+// spread calls across them round-robin. Useful for high-throughput targets
+// where a single HTTP/2 connection's stream limit becomes the bottleneck.
+// The default is a single shared connection.
+func WithPoolSize(n int) Option {
+	return func(o *options) {
+		o.poolSize = n
+	}
+}
+
 type Factory struct{}
 
 func NewFactory() *Factory {
 	return &Factory{}
 }
 
-func (f *Factory) CreateTransport(transportType Type, address string) Transport {
+func (f *Factory) CreateTransport(transportType Type, address string, opts ...Option) Transport {
 	switch transportType {
 	case GRPC:
-		return NewGRPCTransport(address)
+		return NewGRPCTransport(address, opts...)
+	case HTTP:
+		return NewHTTPTransport(address, opts...)
+	case RMQ:
+		return NewRMQTransport(address, opts...)
+	case InProcess:
+		return NewInProcessTransport(address, opts...)
 	default:
 		return nil
 	}